@@ -1,19 +1,46 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	swaggerFiles "github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/otel"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
 	_ "avito_2023/docs"
+	ah "avito_2023/internal/audit/handler"
+	arec "avito_2023/internal/audit/recorder"
+	ar "avito_2023/internal/audit/repo"
+	"avito_2023/internal/auth"
+	"avito_2023/internal/config"
+	"avito_2023/internal/database"
+	"avito_2023/internal/logging"
+	"avito_2023/internal/metrics"
+	rh "avito_2023/internal/report/handler"
+	rr "avito_2023/internal/report/repo"
+	rs "avito_2023/internal/report/storage"
+	"avito_2023/internal/response"
 	sh "avito_2023/internal/segment/handler"
 	sr "avito_2023/internal/segment/repo"
+	"avito_2023/internal/segment/slug"
+	"avito_2023/internal/tracing"
+	th "avito_2023/internal/ttl/handler"
+	tw "avito_2023/internal/ttl/worker"
 	uh "avito_2023/internal/user/handler"
 	ur "avito_2023/internal/user/repo"
 )
@@ -21,7 +48,7 @@ import (
 const (
 	envPath = ".env"
 
-	addr = ":8080"
+	reportsDir = "reports"
 )
 
 // @title Avito Trainee Assignment 2023
@@ -31,33 +58,244 @@ const (
 // @host localhost:8080
 // @BasePath /
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT signed with the client key, "Bearer <token>"
+
 func main() {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+	cfg, err := config.Load(envPath, os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	slog.SetLogLoggerLevel(cfg.Log.SlogLevel())
 
+	gormLogLevel := logger.Warn
+	switch cfg.Log.Level {
+	case config.LogLevelDebug:
+		gormLogLevel = logger.Info
+	case config.LogLevelError:
+		gormLogLevel = logger.Error
+	}
 	db, err := gorm.Open(postgres.New(postgres.Config{
-		DSN:                  dsn,
+		DSN:                  cfg.DB.DSN(),
 		PreferSimpleProtocol: true,
-	}), &gorm.Config{})
+	}), &gorm.Config{Logger: logger.Default.LogMode(gormLogLevel)})
 	if err != nil {
 		log.Fatalf("failed to open db: %s", err)
 	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to get db pool: %s", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.DB.ConnMaxIdleTime)
+
+	tp, err := tracing.NewProvider(context.Background(), cfg.Tracing.Options())
+	if err != nil {
+		log.Fatalf("failed to init tracing: %s", err)
+	}
+	if tp != nil {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(tracing.Propagator())
+		if err := db.Use(tracing.GormPlugin(tp)); err != nil {
+			log.Fatalf("failed to trace db: %s", err)
+		}
+		database.AddMethodHook(tracing.MethodHook(tp))
+	}
+
+	var m *metrics.Metrics
+	if !cfg.Metrics.Disabled {
+		m = metrics.New()
+		if err := db.Use(m.GormPlugin()); err != nil {
+			log.Fatalf("failed to instrument db: %s", err)
+		}
+		database.AddMethodHook(m.MethodHook())
+		if err := m.Register(collectors.NewDBStatsCollector(sqlDB, cfg.DB.Name)); err != nil {
+			log.Fatalf("failed to register db metrics: %s", err)
+		}
+	}
 
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	if cfg.Log.Level == config.LogLevelDebug {
+		gin.SetMode(gin.DebugMode)
+	}
+	r := gin.New()
+	r.Use(logging.Middleware())
+	if tp != nil {
+		r.Use(tracing.Middleware(cfg.Tracing.ServiceName, tp))
+	}
+	if m != nil {
+		// placed before recovery, so panics are observed as 500
+		r.Use(m.Middleware())
+	}
+	r.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		response.Error(c, fmt.Errorf("panic: %v", recovered))
+		c.Abort()
+	}))
+	// client IP is recorded in audit log, so forwarded headers are only trusted from known proxies
+	if err := r.SetTrustedProxies(cfg.HTTP.TrustedProxiesList()); err != nil {
+		log.Fatalf("failed to set trusted proxies: %s", err)
+	}
+	r.NoRoute(func(c *gin.Context) {
+		response.NotFound(c, "page not found")
+	})
+
+	slugPolicy, err := slug.NewPolicy(cfg.Slug.Options())
+	if err != nil {
+		log.Fatalf("failed to init slug policy: %s", err)
+	}
+	if err := slugPolicy.RegisterValidation(); err != nil {
+		log.Fatalf("failed to register slug validation: %s", err)
+	}
+
+	var guard auth.Guard = auth.Disabled{}
+	if cfg.Auth.Disabled {
+		slog.Warn("authentication is disabled, every request is let through")
+	} else {
+		clients, err := auth.LoadClients(cfg.Auth.ClientsFile)
+		if err != nil {
+			log.Fatalf("failed to load auth clients: %s", err)
+		}
+		guard = auth.NewAuthenticator(clients)
+	}
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	auditRepo := ar.NewRepo(db)
+	auditRecorder := arec.NewRecorder(auditRepo)
+	auditHandler := ah.NewHandler(auditRepo)
+	ah.Route(r, auditHandler, guard)
+
 	segmentRepo := sr.NewRepo(db)
-	segmentHandler := sh.NewHandler(segmentRepo)
-	sh.Route(r, segmentHandler)
+	segmentHandler := sh.NewHandler(segmentRepo, slugPolicy, cfg.Auth.PurgeToken)
+	sh.Route(r, segmentHandler, guard, auditRecorder)
 
 	userRepo := ur.NewRepo(db)
 	userHandler := uh.NewHandler(userRepo)
-	uh.Route(r, userHandler)
+	uh.Route(r, userHandler, guard, auditRecorder)
+
+	reportStorage, err := rs.NewLocalStorage(reportsDir)
+	if err != nil {
+		log.Fatalf("failed to init reports storage: %s", err)
+	}
+	reportRepo := rr.NewRepo(db)
+	reportHandler := rh.NewHandler(reportRepo, reportStorage)
+	rh.Route(r, reportHandler, guard)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	ttlWorker := tw.NewWorker(userRepo, cfg.TTL.Interval, cfg.TTL.BatchSize)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ttlWorker.Run(ctx)
+	}()
+	ttlHandler := th.NewHandler(ttlWorker)
+	th.Route(r, ttlHandler, guard)
 
-	log.Println("Starting app...")
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("failed to run server: %s", err)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		rs.RunCleanup(ctx, reportStorage, cfg.Reports.TTL)
+	}()
+
+	if m != nil {
+		if err := m.Register(metrics.NewMembersCollector(segmentRepo, cfg.Metrics.MembersRefresh)); err != nil {
+			log.Fatalf("failed to register segments metrics: %s", err)
+		}
+		if err := m.Register(metrics.NewTTLCollectors(ttlWorker)...); err != nil {
+			log.Fatalf("failed to register ttl worker metrics: %s", err)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           r,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// scrapers don't authenticate, so metrics are served on their own listener, which isn't exposed with the API
+	var metricsSrv *http.Server
+	if m != nil {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", m.Handler())
+		metricsSrv = &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			WriteTimeout:      cfg.HTTP.WriteTimeout,
+		}
+		go func() {
+			serveErr <- metricsSrv.ListenAndServe()
+		}()
+		slog.Info("serving metrics", "addr", cfg.Metrics.Addr)
+	}
+
+	slog.Info("starting app", "addr", cfg.HTTP.Addr)
+	failed := false
+	select {
+	case err := <-serveErr:
+		slog.Error("failed to run server", "error", err)
+		failed = true
+		stop()
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	}
+
+	// in-flight requests and worker batches are drained within the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shutdown server", "error", err)
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown metrics server", "error", err)
+		}
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("background workers didn't stop in time")
+	}
+
+	if tp != nil {
+		// flushes spans batched by the exporter
+		if err := tp.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown tracing", "error", err)
+		}
+	}
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("failed to close db", "error", err)
+	}
+	if failed {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/repo"
+	"avito_2023/internal/auth"
+	"avito_2023/internal/response"
+)
+
+type Handler struct {
+	repo repo.Repo
+}
+
+// @Summary Get Audit Log
+// @Tags audit
+// @Description Get audit entries of mutating requests from the newest, pass next_cursor from the response to get the next page
+// @Produce json
+// @Param actor query string false "client name"
+// @Param segment query string false "segment slug"
+// @Param user_id query int false "user ID"
+// @Param from query int false "unix time of the earliest entry"
+// @Param to query int false "unix time the entries are before"
+// @Param cursor query int false "last entry ID of the previous page"
+// @Param limit query int false "page size" default(100)
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *Handler) getEntries(c *gin.Context) {
+	var query GetEntriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	if query.From != 0 && query.To != 0 && query.From >= query.To {
+		response.Validation(c, "from must be before to")
+		return
+	}
+
+	filter := &model.EntriesFilter{
+		Actor:   query.Actor,
+		Segment: query.Segment,
+		UserID:  query.UserID,
+		Before:  query.Cursor,
+		Limit:   query.Limit,
+	}
+	if query.From != 0 {
+		from := time.Unix(query.From, 0)
+		filter.From = &from
+	}
+	if query.To != 0 {
+		to := time.Unix(query.To, 0)
+		filter.To = &to
+	}
+	entries, err := h.repo.GetEntries(c.Request.Context(), filter)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	if entries == nil {
+		entries = []*model.EntryDB{}
+	}
+
+	var nextCursor *uint
+	if len(entries) == query.Limit {
+		nextCursor = &entries[len(entries)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "next_cursor": nextCursor})
+}
+
+func NewHandler(repo repo.Repo) *Handler {
+	return &Handler{
+		repo: repo,
+	}
+}
+
+func Route(r *gin.Engine, h *Handler, guard auth.Guard) {
+	r.GET("/audit", guard.Require(auth.RoleAdmin), h.getEntries)
+}
@@ -0,0 +1,157 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"avito_2023/internal/audit/handler"
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/repo/mocks"
+	"avito_2023/internal/auth"
+)
+
+type Suite struct {
+	suite.Suite
+
+	r       *gin.Engine
+	repo    *mocks.RepoMock
+	handler *handler.Handler
+}
+
+func (s *Suite) SetupSuite() {
+	s.repo = &mocks.RepoMock{}
+	s.handler = handler.NewHandler(s.repo)
+
+	gin.SetMode(gin.TestMode)
+	s.r = gin.Default()
+
+	handler.Route(s.r, s.handler, auth.Disabled{})
+}
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, &Suite{})
+}
+
+func (s *Suite) TestGetEntries() {
+	at := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		query        string
+		mockFc       func(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:  "get entries",
+			query: "?actor=admin-cli&segment=AVITO_VOICE_MESSAGES&from=1692057600&to=1692144000&limit=1",
+			mockFc: func(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error) {
+				if filter.Actor != "admin-cli" || filter.Segment != "AVITO_VOICE_MESSAGES" || filter.UserID != nil {
+					return nil, fmt.Errorf("unexpected filter %+v", filter)
+				}
+				if filter.From == nil || filter.From.Unix() != 1692057600 || filter.To == nil || filter.To.Unix() != 1692144000 {
+					return nil, fmt.Errorf("unexpected time range")
+				}
+				return []*model.EntryDB{
+					{
+						ID:         7,
+						Actor:      "admin-cli",
+						Subject:    "j.doe",
+						Role:       "admin",
+						ClientIP:   "192.0.2.1",
+						Action:     model.ActionSegmentArchive,
+						Method:     http.MethodDelete,
+						Path:       "/segment/delete",
+						Segments:   []string{"avito_voice_messages"},
+						UsersIDs:   []uint{},
+						BodySHA256: "0a",
+						Status:     http.StatusNoContent,
+						CreatedAt:  at,
+					},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "entries": [
+				    {
+				      "id": 7,
+				      "actor": "admin-cli",
+				      "subject": "j.doe",
+				      "role": "admin",
+				      "client_ip": "192.0.2.1",
+				      "action": "segment.archive",
+				      "method": "DELETE",
+				      "path": "/segment/delete",
+				      "segments": ["avito_voice_messages"],
+				      "users_ids": [],
+				      "users_count": 0,
+				      "body_sha256": "0a",
+				      "status": 204,
+				      "created_at": "2023-08-15T12:30:00Z"
+				    }
+				  ],
+				  "next_cursor": 7
+				}
+			`,
+		},
+		{
+			name:  "no entries",
+			query: "?user_id=1000&cursor=7",
+			mockFc: func(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error) {
+				if filter.UserID == nil || *filter.UserID != 1000 || filter.Before != 7 || filter.Limit != 100 {
+					return nil, fmt.Errorf("unexpected filter %+v", filter)
+				}
+				return nil, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `{"entries": [], "next_cursor": null}`,
+		},
+		{
+			name:         "invalid time range",
+			query:        "?from=1692144000&to=1692057600",
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `{"error": {"code": "validation_failed", "message": "from must be before to"}}`,
+		},
+		{
+			name:         "invalid limit",
+			query:        "?limit=5000",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:  "failed to get entries from db",
+			query: "",
+			mockFc: func(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedResp: `{"error": {"code": "internal_error", "message": "internal error"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.GetEntriesFunc = tc.mockFc
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/audit"+tc.query, nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package handler
+
+type GetEntriesQuery struct {
+	Actor   string `form:"actor"`
+	Segment string `form:"segment"`
+	UserID  *uint  `form:"user_id"`
+	// From and To are unix seconds, To is exclusive
+	From   int64 `form:"from" binding:"min=0"`
+	To     int64 `form:"to" binding:"min=0"`
+	Cursor uint  `form:"cursor"`
+	Limit  int   `form:"limit,default=100" binding:"min=1,max=1000"`
+}
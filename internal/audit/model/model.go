@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+)
+
+// ActorAnonymous is recorded when authentication is disabled
+const ActorAnonymous = "anonymous"
+
+// Actions of audited mutations
+const (
+	ActionSegmentAdd     = "segment.add"
+	ActionSegmentUpdate  = "segment.update"
+	ActionSegmentArchive = "segment.archive"
+	ActionSegmentPurge   = "segment.purge"
+
+	ActionUserCreate         = "user.create"
+	ActionUserErase          = "user.erase"
+	ActionUserSegmentsUpdate = "user.segments.update"
+	ActionUserSegmentsUpload = "user.segments.upload"
+
+	ActionUsersSegmentsUpdate = "users.segments.update"
+)
+
+// EntryDB is an append-only record of a mutating request and its outcome
+type EntryDB struct {
+	ID      uint   `gorm:"id" json:"id"`
+	Actor   string `gorm:"actor" json:"actor"`
+	Subject string `gorm:"subject" json:"subject,omitempty"`
+	Role    string `gorm:"role" json:"role,omitempty"`
+	// ClientIP is taken from X-Forwarded-For only behind trusted proxies
+	ClientIP string `gorm:"client_ip" json:"client_ip"`
+	Action   string `gorm:"action" json:"action"`
+	Method   string `gorm:"method" json:"method"`
+	// Path is the route, e.g. /user/:user_id, its parameters are recorded as segments and users,
+	// so erasing a user doesn't have to rewrite paths
+	Path string `gorm:"path" json:"path"`
+	// Segments are slugs the request refers to in lower case
+	Segments []string `gorm:"segments;serializer:json" json:"segments"`
+	// UsersIDs are users the request refers to, UsersCount is their total number.
+	// Erased users are removed from UsersIDs, erase itself records none.
+	UsersIDs   []uint `gorm:"users_ids;serializer:json" json:"users_ids"`
+	UsersCount int    `gorm:"users_count" json:"users_count"`
+	// BodySHA256 is empty for unauthenticated requests
+	BodySHA256 string    `gorm:"body_sha256" json:"body_sha256,omitempty"`
+	Status     int       `gorm:"status" json:"status"`
+	ErrorCode  string    `gorm:"error_code" json:"error_code,omitempty"`
+	CreatedAt  time.Time `gorm:"created_at" json:"created_at"`
+}
+
+func (EntryDB) TableName() string {
+	return "audit_log"
+}
+
+// EntriesFilter selects a page of audit entries ordered from the newest
+type EntriesFilter struct {
+	Actor   string
+	Segment string
+	UserID  *uint
+	From    *time.Time
+	To      *time.Time
+	// Before is the id of the last entry of the previous page
+	Before uint
+	Limit  int
+}
@@ -0,0 +1,164 @@
+package recorder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/repo"
+	"avito_2023/internal/auth"
+	"avito_2023/internal/response"
+)
+
+const (
+	segmentsKey = "audit.segments"
+	usersKey    = "audit.users"
+
+	// maxUsers bounds users ids kept per entry, bulk uploads may refer to many more
+	maxUsers = 1000
+
+	// unauthenticated requests are recorded at most at this rate, so they can't flood audit log
+	unauthenticatedRate  = rate.Limit(10)
+	unauthenticatedBurst = 20
+)
+
+// Recorder makes middleware writing the request and its outcome to audit log
+type Recorder interface {
+	Record(action string) gin.HandlerFunc
+}
+
+// Disabled records nothing, it is meant for tests
+type Disabled struct{}
+
+func (Disabled) Record(string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+	}
+}
+
+type recorder struct {
+	repo            repo.Repo
+	unauthenticated *rate.Limiter
+}
+
+func NewRecorder(repo repo.Repo) Recorder {
+	return &recorder{
+		repo:            repo,
+		unauthenticated: rate.NewLimiter(unauthenticatedRate, unauthenticatedBurst),
+	}
+}
+
+// Record writes audit entry after the handler is done. It must go before authentication,
+// so denied requests are recorded too, the caller is read from the request after the chain is done.
+// Unauthenticated requests are recorded without body digest and are rate limited.
+// Failure to write the entry is logged and doesn't affect the response.
+func (r *recorder) Record(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		digest := sha256.New()
+		body := c.Request.Body
+		if body != nil {
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(body, digest), body}
+		}
+
+		c.Next()
+
+		// the body of unauthenticated request isn't read, so denials cost no more than the request itself
+		unauthenticated := c.Writer.Status() == http.StatusUnauthorized
+		if unauthenticated && !r.unauthenticated.Allow() {
+			return
+		}
+		bodySHA256 := ""
+		if !unauthenticated {
+			// the part of the body the handler didn't read still counts in the digest
+			if body != nil {
+				if _, err := io.Copy(digest, body); err != nil {
+					slog.WarnContext(c.Request.Context(), "failed to read request body for audit", "error", err)
+				}
+			}
+			bodySHA256 = hex.EncodeToString(digest.Sum(nil))
+		}
+
+		entry := &model.EntryDB{
+			Actor:      model.ActorAnonymous,
+			ClientIP:   c.ClientIP(),
+			Action:     action,
+			Method:     c.Request.Method,
+			Path:       c.FullPath(),
+			Segments:   []string{},
+			UsersIDs:   []uint{},
+			BodySHA256: bodySHA256,
+			Status:     c.Writer.Status(),
+		}
+		if principal, ok := auth.FromContext(c.Request.Context()); ok {
+			entry.Actor = principal.Client
+			entry.Subject = fitColumn(principal.Subject, auth.MaxSubjectLength)
+			entry.Role = string(principal.Role)
+		}
+		if code, ok := response.ErrorCode(c); ok {
+			entry.ErrorCode = string(code)
+		}
+		if segments, ok := c.Get(segmentsKey); ok {
+			slugs := segments.([]string)
+			slices.Sort(slugs)
+			entry.Segments = slices.Compact(slugs)
+		}
+		if users, ok := c.Get(usersKey); ok {
+			ids := users.([]uint)
+			entry.UsersCount = len(ids)
+			entry.UsersIDs = ids[:min(len(ids), maxUsers)]
+		}
+
+		// the entry is written even if the client has gone
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := r.repo.AddEntry(ctx, entry); err != nil {
+			slog.ErrorContext(ctx, "failed to write audit entry", "action", action, "error", err)
+		}
+	}
+}
+
+// fitColumn makes caller-controlled s storable in a text column of size characters:
+// invalid UTF-8 and NUL bytes are rejected by postgres, longer values are cut
+func fitColumn(s string, size int) string {
+	s = strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "")
+	if utf8.RuneCountInString(s) <= size {
+		return s
+	}
+
+	return string([]rune(s)[:size])
+}
+
+// Segments adds slugs the request refers to the audit entry
+func Segments(c *gin.Context, slugs ...string) {
+	var segments []string
+	if v, ok := c.Get(segmentsKey); ok {
+		segments = v.([]string)
+	}
+	for _, slug := range slugs {
+		segments = append(segments, strings.ToLower(slug))
+	}
+
+	c.Set(segmentsKey, segments)
+}
+
+// Users adds users the request refers to the audit entry
+func Users(c *gin.Context, ids ...uint) {
+	var users []uint
+	if v, ok := c.Get(usersKey); ok {
+		users = v.([]uint)
+	}
+
+	c.Set(usersKey, append(users, ids...))
+}
@@ -0,0 +1,255 @@
+package recorder_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/recorder"
+	"avito_2023/internal/audit/repo/mocks"
+	"avito_2023/internal/auth"
+	"avito_2023/internal/database"
+	"avito_2023/internal/response"
+)
+
+func TestRecord(t *testing.T) {
+	const body = `{"slug": "AVITO_VOICE_MESSAGES", "description": "voice messages"}`
+	digest := sha256.Sum256([]byte(body))
+
+	users := make([]uint, 1500)
+	for i := range users {
+		users[i] = uint(i + 1)
+	}
+
+	testCases := []struct {
+		name      string
+		principal *auth.Principal
+		addErr    error
+		expected  *model.EntryDB
+	}{
+		{
+			name:      "authenticated",
+			principal: &auth.Principal{Client: "admin-cli", Role: auth.RoleAdmin, Subject: "j.doe"},
+			expected: &model.EntryDB{
+				Actor:      "admin-cli",
+				Subject:    "j.doe",
+				Role:       "admin",
+				ClientIP:   "192.0.2.1",
+				Action:     model.ActionSegmentArchive,
+				Method:     http.MethodPost,
+				Path:       "/segment/delete",
+				Segments:   []string{"avito_voice_messages"},
+				UsersIDs:   users[:1000],
+				UsersCount: 1500,
+				BodySHA256: hex.EncodeToString(digest[:]),
+				Status:     http.StatusNotFound,
+				ErrorCode:  "not_found",
+			},
+		},
+		{
+			name:   "anonymous, failed to write entry",
+			addErr: errors.New("something went wrong"),
+			expected: &model.EntryDB{
+				Actor:      model.ActorAnonymous,
+				ClientIP:   "192.0.2.1",
+				Action:     model.ActionSegmentArchive,
+				Method:     http.MethodPost,
+				Path:       "/segment/delete",
+				Segments:   []string{"avito_voice_messages"},
+				UsersIDs:   users[:1000],
+				UsersCount: 1500,
+				BodySHA256: hex.EncodeToString(digest[:]),
+				Status:     http.StatusNotFound,
+				ErrorCode:  "not_found",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var recorded *model.EntryDB
+			repo := &mocks.RepoMock{
+				AddEntryFunc: func(ctx context.Context, entry *model.EntryDB) error {
+					recorded = entry
+					return tc.addErr
+				},
+			}
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/segment/delete", func(c *gin.Context) {
+				if tc.principal != nil {
+					c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), tc.principal))
+				}
+			}, recorder.NewRecorder(repo).Record(model.ActionSegmentArchive), func(c *gin.Context) {
+				// the handler reads only a part of the body
+				_, err := io.ReadFull(c.Request.Body, make([]byte, 10))
+				require.NoError(t, err)
+
+				recorder.Segments(c, "AVITO_VOICE_MESSAGES", "avito_voice_messages")
+				recorder.Users(c, users...)
+				response.Error(c, database.NewError(database.CodeNotFound, "segment AVITO_VOICE_MESSAGES not found"))
+			})
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/segment/delete", strings.NewReader(body))
+			req.RemoteAddr = "192.0.2.1:40000"
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, http.StatusNotFound, res.Code)
+			assert.Equal(t, tc.expected, recorded)
+		})
+	}
+}
+
+func TestRecordDenied(t *testing.T) {
+	const body = `{"slug": "AVITO_VOICE_MESSAGES"}`
+	digest := sha256.Sum256([]byte(body))
+
+	clients, err := auth.ParseClients([]byte(`
+clients:
+  - name: dashboard
+    role: reader
+    api_key_sha256: `+auth.HashAPIKey("test-reader-key")+`
+`), t.TempDir())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		apiKey         string
+		expectedActor  string
+		expectedStatus int
+		expectedCode   string
+		expectedSHA256 string
+	}{
+		{
+			name:           "unauthenticated",
+			expectedActor:  model.ActorAnonymous,
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "forbidden",
+			apiKey:         "test-reader-key",
+			expectedActor:  "dashboard",
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "forbidden",
+			expectedSHA256: hex.EncodeToString(digest[:]),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var recorded *model.EntryDB
+			repo := &mocks.RepoMock{
+				AddEntryFunc: func(ctx context.Context, entry *model.EntryDB) error {
+					recorded = entry
+					return nil
+				},
+			}
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.DELETE("/segment/purge",
+				recorder.NewRecorder(repo).Record(model.ActionSegmentPurge),
+				auth.NewAuthenticator(clients).Require(auth.RoleAdmin),
+				func(c *gin.Context) {
+					t.Fatal("handler must not be reached")
+				},
+			)
+
+			res := httptest.NewRecorder()
+			reader := strings.NewReader(body)
+			req, _ := http.NewRequest(http.MethodDelete, "/segment/purge", reader)
+			if tc.apiKey != "" {
+				req.Header.Set(auth.APIKeyHeader, tc.apiKey)
+			}
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedStatus, res.Code)
+			require.NotNil(t, recorded)
+			assert.Equal(t, tc.expectedActor, recorded.Actor)
+			assert.Equal(t, model.ActionSegmentPurge, recorded.Action)
+			assert.Equal(t, tc.expectedStatus, recorded.Status)
+			assert.Equal(t, tc.expectedCode, recorded.ErrorCode)
+			assert.Equal(t, tc.expectedSHA256, recorded.BodySHA256)
+			if tc.expectedSHA256 == "" {
+				assert.Equal(t, len(body), reader.Len(), "body of unauthenticated request must not be read")
+			}
+		})
+	}
+}
+
+func TestRecordUnauthenticatedLimit(t *testing.T) {
+	var recorded int
+	repo := &mocks.RepoMock{
+		AddEntryFunc: func(ctx context.Context, entry *model.EntryDB) error {
+			recorded++
+			return nil
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/segment/add", recorder.NewRecorder(repo).Record(model.ActionSegmentAdd), func(c *gin.Context) {
+		response.Error(c, database.NewError(database.CodeUnauthorized, "missing credentials"))
+		c.Abort()
+	})
+
+	for range 100 {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/segment/add", nil)
+		r.ServeHTTP(res, req)
+		require.Equal(t, http.StatusUnauthorized, res.Code)
+	}
+
+	// the burst is recorded, the rest is dropped until the limiter refills
+	assert.Less(t, recorded, 100)
+	assert.GreaterOrEqual(t, recorded, 20)
+}
+
+func TestRecordLongValues(t *testing.T) {
+	var recorded *model.EntryDB
+	repo := &mocks.RepoMock{
+		AddEntryFunc: func(ctx context.Context, entry *model.EntryDB) error {
+			recorded = entry
+			return nil
+		},
+	}
+
+	// the subject is cut by characters, the path is the route whatever the slug is
+	subject := strings.Repeat("я", 300)
+	slug := strings.Repeat("a", 300)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/segment/:slug", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), &auth.Principal{
+			Client:  "admin-cli",
+			Role:    auth.RoleAdmin,
+			Subject: subject,
+		}))
+	}, recorder.NewRecorder(repo).Record(model.ActionSegmentUpdate), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/segment/%00"+slug, nil)
+	r.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	require.NotNil(t, recorded)
+	assert.Equal(t, strings.Repeat("я", auth.MaxSubjectLength), recorded.Subject)
+	assert.Equal(t, "/segment/:slug", recorded.Path)
+}
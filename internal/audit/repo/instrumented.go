@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"context"
+
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/database"
+)
+
+// instrumentedRepo attributes queries of every repo method to it and reports the method
+// to hooks registered with database.AddMethodHook
+type instrumentedRepo struct {
+	repo Repo
+}
+
+func (r *instrumentedRepo) AddEntry(ctx context.Context, entry *model.EntryDB) (err error) {
+	ctx, end := database.StartMethod(ctx, "audit.AddEntry")
+	defer func() { end(err) }()
+
+	return r.repo.AddEntry(ctx, entry)
+}
+
+func (r *instrumentedRepo) GetEntries(ctx context.Context, filter *model.EntriesFilter) (result []*model.EntryDB, err error) {
+	ctx, end := database.StartMethod(ctx, "audit.GetEntries")
+	defer func() { end(err) }()
+
+	return r.repo.GetEntries(ctx, filter)
+}
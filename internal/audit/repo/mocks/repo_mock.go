@@ -0,0 +1,133 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/repo"
+	"context"
+	"sync"
+)
+
+// Ensure, that RepoMock does implement repo.Repo.
+// If this is not the case, regenerate this file with moq.
+var _ repo.Repo = &RepoMock{}
+
+// RepoMock is a mock implementation of repo.Repo.
+//
+//	func TestSomethingThatUsesRepo(t *testing.T) {
+//
+//		// make and configure a mocked repo.Repo
+//		mockedRepo := &RepoMock{
+//			AddEntryFunc: func(ctx context.Context, entry *model.EntryDB) error {
+//				panic("mock out the AddEntry method")
+//			},
+//			GetEntriesFunc: func(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error) {
+//				panic("mock out the GetEntries method")
+//			},
+//		}
+//
+//		// use mockedRepo in code that requires repo.Repo
+//		// and then make assertions.
+//
+//	}
+type RepoMock struct {
+	// AddEntryFunc mocks the AddEntry method.
+	AddEntryFunc func(ctx context.Context, entry *model.EntryDB) error
+
+	// GetEntriesFunc mocks the GetEntries method.
+	GetEntriesFunc func(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AddEntry holds details about calls to the AddEntry method.
+		AddEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Entry is the entry argument value.
+			Entry *model.EntryDB
+		}
+		// GetEntries holds details about calls to the GetEntries method.
+		GetEntries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter *model.EntriesFilter
+		}
+	}
+	lockAddEntry   sync.RWMutex
+	lockGetEntries sync.RWMutex
+}
+
+// AddEntry calls AddEntryFunc.
+func (mock *RepoMock) AddEntry(ctx context.Context, entry *model.EntryDB) error {
+	if mock.AddEntryFunc == nil {
+		panic("RepoMock.AddEntryFunc: method is nil but Repo.AddEntry was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Entry *model.EntryDB
+	}{
+		Ctx:   ctx,
+		Entry: entry,
+	}
+	mock.lockAddEntry.Lock()
+	mock.calls.AddEntry = append(mock.calls.AddEntry, callInfo)
+	mock.lockAddEntry.Unlock()
+	return mock.AddEntryFunc(ctx, entry)
+}
+
+// AddEntryCalls gets all the calls that were made to AddEntry.
+// Check the length with:
+//
+//	len(mockedRepo.AddEntryCalls())
+func (mock *RepoMock) AddEntryCalls() []struct {
+	Ctx   context.Context
+	Entry *model.EntryDB
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Entry *model.EntryDB
+	}
+	mock.lockAddEntry.RLock()
+	calls = mock.calls.AddEntry
+	mock.lockAddEntry.RUnlock()
+	return calls
+}
+
+// GetEntries calls GetEntriesFunc.
+func (mock *RepoMock) GetEntries(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error) {
+	if mock.GetEntriesFunc == nil {
+		panic("RepoMock.GetEntriesFunc: method is nil but Repo.GetEntries was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter *model.EntriesFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockGetEntries.Lock()
+	mock.calls.GetEntries = append(mock.calls.GetEntries, callInfo)
+	mock.lockGetEntries.Unlock()
+	return mock.GetEntriesFunc(ctx, filter)
+}
+
+// GetEntriesCalls gets all the calls that were made to GetEntries.
+// Check the length with:
+//
+//	len(mockedRepo.GetEntriesCalls())
+func (mock *RepoMock) GetEntriesCalls() []struct {
+	Ctx    context.Context
+	Filter *model.EntriesFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter *model.EntriesFilter
+	}
+	mock.lockGetEntries.RLock()
+	calls = mock.calls.GetEntries
+	mock.lockGetEntries.RUnlock()
+	return calls
+}
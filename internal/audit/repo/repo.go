@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"avito_2023/internal/audit/model"
+	"avito_2023/internal/database"
+)
+
+//go:generate moq --out mocks/repo_mock.go --pkg=mocks . Repo
+
+type Repo interface {
+	// AddEntry - append entry to audit log
+	AddEntry(ctx context.Context, entry *model.EntryDB) error
+
+	// GetEntries - get audit entries matching filter from the newest
+	GetEntries(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error)
+}
+
+type repo struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repo {
+	return &instrumentedRepo{
+		repo: &repo{
+			db: db,
+		},
+	}
+}
+
+func (r *repo) AddEntry(ctx context.Context, entry *model.EntryDB) error {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	return db.Create(entry).Error
+}
+
+func (r *repo) GetEntries(ctx context.Context, filter *model.EntriesFilter) ([]*model.EntryDB, error) {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	query := db.Model(&model.EntryDB{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	// jsonb containment is served by GIN indexes
+	if filter.Segment != "" {
+		segments, err := json.Marshal([]string{strings.ToLower(filter.Segment)})
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("segments @> ?::jsonb", string(segments))
+	}
+	if filter.UserID != nil {
+		usersIDs, err := json.Marshal([]uint{*filter.UserID})
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("users_ids @> ?::jsonb", string(usersIDs))
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.Before != 0 {
+		query = query.Where("id < ?", filter.Before)
+	}
+
+	var entries []*model.EntryDB
+	if err := query.Order("id DESC").
+		Limit(filter.Limit).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
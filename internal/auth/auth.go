@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/response"
+)
+
+const (
+	// APIKeyHeader carries client API key
+	APIKeyHeader = "X-API-Key"
+
+	bearerPrefix = "Bearer "
+
+	// leeway tolerates clock skew between the service and JWT issuers
+	leeway = 30 * time.Second
+
+	// MaxSubjectLength bounds sub claim of JWT in characters, it is the size of audit log subject column
+	MaxSubjectLength = 255
+)
+
+type Role string
+
+const (
+	// RoleReader reads users segments, segments and reports
+	RoleReader Role = "reader"
+	// RoleEditor also changes segments memberships and creates users
+	RoleEditor Role = "editor"
+	// RoleAdmin also creates, updates, archives and purges segments and erases users,
+	// purge also requires the purge token
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// Includes reports whether the role grants everything other role does
+func (r Role) Includes(other Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[other]
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+var (
+	errNoCredentials      = errors.New("missing credentials")
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Guard makes middleware allowing requests of clients with the role
+type Guard interface {
+	Require(role Role) gin.HandlerFunc
+}
+
+// Disabled lets every request through, it is meant for local development and tests
+type Disabled struct{}
+
+func (Disabled) Require(Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+	}
+}
+
+// Authenticator authenticates clients by API key or JWT signed with the client key
+type Authenticator struct {
+	byName   map[string]*Client
+	byAPIKey map[string]*Client
+}
+
+func NewAuthenticator(clients []*Client) *Authenticator {
+	a := &Authenticator{
+		byName:   make(map[string]*Client, len(clients)),
+		byAPIKey: make(map[string]*Client, len(clients)),
+	}
+	for _, client := range clients {
+		a.byName[client.Name] = client
+		if client.apiKeyHash != nil {
+			a.byAPIKey[string(client.apiKeyHash)] = client
+		}
+	}
+
+	return a
+}
+
+// Require authenticates the request and lets it through if the client role includes role
+func (a *Authenticator) Require(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := a.authenticate(c)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			response.Error(c, database.NewError(database.CodeUnauthorized, err.Error()))
+			c.Abort()
+			return
+		}
+		// the principal is kept for denied requests too, so audit log shows who was denied
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), principal))
+		if !principal.Role.Includes(role) {
+			response.Error(c, database.NewError(database.CodeForbidden, "role "+string(role)+" is required"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (a *Authenticator) authenticate(c *gin.Context) (*Principal, error) {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+	if header := c.GetHeader("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, bearerPrefix)
+		if !ok {
+			return nil, errInvalidCredentials
+		}
+		return a.authenticateJWT(token)
+	}
+
+	return nil, errNoCredentials
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (*Principal, error) {
+	hash := sha256.Sum256([]byte(key))
+	// keys are looked up by hash, so lookup time doesn't depend on the key itself
+	client, ok := a.byAPIKey[string(hash[:])]
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+
+	return &Principal{Client: client.Name, Role: client.Role}, nil
+}
+
+// authenticateJWT verifies token against the key of the client named by iss claim
+func (a *Authenticator) authenticateJWT(raw string) (*Principal, error) {
+	var client *Client
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		issuer, err := token.Claims.GetIssuer()
+		if err != nil {
+			return nil, err
+		}
+		c, ok := a.byName[issuer]
+		if !ok || c.jwtKey == nil || token.Method.Alg() != c.jwtAlg {
+			return nil, errInvalidCredentials
+		}
+
+		client = c
+		return c.jwtKey, nil
+	}, jwt.WithValidMethods(jwtAlgs), jwt.WithExpirationRequired(), jwt.WithLeeway(leeway))
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+	if utf8.RuneCountInString(claims.Subject) > MaxSubjectLength {
+		return nil, errInvalidCredentials
+	}
+
+	return &Principal{Client: client.Name, Role: client.Role, Subject: claims.Subject}, nil
+}
+
+// HashAPIKey returns hex sha256 of the key as expected in clients file
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Client is the name of the configured client
+	Client string
+	Role   Role
+	// Subject is sub claim of JWT, e.g. a person acting through the client, empty for API keys
+	Subject string
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns authenticated caller of the request, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
@@ -0,0 +1,254 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avito_2023/internal/auth"
+)
+
+const (
+	testReaderKey = "test-reader-key"
+	testHSSecret  = "test-secret-at-least-32-bytes-long"
+)
+
+func newRouter(t *testing.T) (*gin.Engine, *rsa.PrivateKey) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "admin.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	path := filepath.Join(dir, "clients.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`
+clients:
+  - name: dashboard
+    role: reader
+    api_key_sha256: %s
+  - name: marketing
+    role: editor
+    jwt:
+      alg: HS256
+      secret: %s
+  - name: admin-cli
+    role: admin
+    jwt:
+      alg: RS256
+      public_key_file: admin.pem
+`, auth.HashAPIKey(testReaderKey), testHSSecret)), 0o600))
+
+	clients, err := auth.LoadClients(path)
+	require.NoError(t, err)
+	a := auth.NewAuthenticator(clients)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	for _, role := range []auth.Role{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin} {
+		r.GET("/"+string(role), a.Require(role), func(c *gin.Context) {
+			principal, ok := auth.FromContext(c.Request.Context())
+			if !ok {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			c.String(http.StatusOK, strings.TrimSuffix(principal.Client+" "+principal.Subject, " "))
+		})
+	}
+
+	return r, rsaKey
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+func TestRequire(t *testing.T) {
+	r, rsaKey := newRouter(t)
+
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	testCases := []struct {
+		name          string
+		path          string
+		apiKey        string
+		authorization string
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name:         "api key",
+			path:         "/reader",
+			apiKey:       testReaderKey,
+			expectedCode: http.StatusOK,
+			expectedBody: "dashboard",
+		},
+		{
+			name:         "api key role is too low",
+			path:         "/editor",
+			apiKey:       testReaderKey,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":{"code":"forbidden","message":"role editor is required"}}`,
+		},
+		{
+			name:         "wrong api key",
+			path:         "/reader",
+			apiKey:       "wrong",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"error":{"code":"unauthorized","message":"invalid credentials"}}`,
+		},
+		{
+			name:         "missing credentials",
+			path:         "/reader",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"error":{"code":"unauthorized","message":"missing credentials"}}`,
+		},
+		{
+			name:          "hs256 token, higher role includes lower",
+			path:          "/reader",
+			authorization: sign(t, jwt.SigningMethodHS256, []byte(testHSSecret), jwt.RegisteredClaims{Issuer: "marketing", ExpiresAt: exp}),
+			expectedCode:  http.StatusOK,
+			expectedBody:  "marketing",
+		},
+		{
+			name:          "rs256 token",
+			path:          "/admin",
+			authorization: sign(t, jwt.SigningMethodRS256, rsaKey, jwt.RegisteredClaims{Issuer: "admin-cli", Subject: "j.doe", ExpiresAt: exp}),
+			expectedCode:  http.StatusOK,
+			expectedBody:  "admin-cli j.doe",
+		},
+		{
+			name:          "expired token",
+			path:          "/reader",
+			authorization: sign(t, jwt.SigningMethodHS256, []byte(testHSSecret), jwt.RegisteredClaims{Issuer: "marketing", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour))}),
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "token without expiration",
+			path:          "/reader",
+			authorization: sign(t, jwt.SigningMethodHS256, []byte(testHSSecret), jwt.RegisteredClaims{Issuer: "marketing"}),
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "token signed with other client key",
+			path:          "/admin",
+			authorization: sign(t, jwt.SigningMethodHS256, []byte(testHSSecret), jwt.RegisteredClaims{Issuer: "admin-cli", ExpiresAt: exp}),
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "unknown issuer",
+			path:          "/reader",
+			authorization: sign(t, jwt.SigningMethodHS256, []byte(testHSSecret), jwt.RegisteredClaims{Issuer: "unknown", ExpiresAt: exp}),
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "too long subject",
+			path:          "/reader",
+			authorization: sign(t, jwt.SigningMethodHS256, []byte(testHSSecret), jwt.RegisteredClaims{Issuer: "marketing", Subject: strings.Repeat("a", auth.MaxSubjectLength+1), ExpiresAt: exp}),
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "not bearer",
+			path:          "/reader",
+			authorization: "Basic dXNlcjpwYXNz",
+			expectedCode:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.apiKey != "" {
+				req.Header.Set(auth.APIKeyHeader, tc.apiKey)
+			}
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseClientsInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        string
+		expectedErr string
+	}{
+		{
+			name:        "no clients",
+			data:        "clients: []",
+			expectedErr: "no clients configured",
+		},
+		{
+			name:        "unknown role",
+			data:        "clients: [{name: a, role: owner, api_key_sha256: " + auth.HashAPIKey("a") + "}]",
+			expectedErr: `clients[0]: role "owner" is not one of reader, editor, admin`,
+		},
+		{
+			name:        "no credentials",
+			data:        "clients: [{name: a, role: reader}]",
+			expectedErr: "clients[0]: api_key_sha256 or jwt is required",
+		},
+		{
+			name:        "plain api key",
+			data:        "clients: [{name: a, role: reader, api_key_sha256: secret}]",
+			expectedErr: "clients[0]: api_key_sha256 must be hex encoded sha256",
+		},
+		{
+			name:        "none alg",
+			data:        "clients: [{name: a, role: reader, jwt: {alg: none}}]",
+			expectedErr: `clients[0]: jwt alg "none" is not supported`,
+		},
+		{
+			name:        "short secret",
+			data:        "clients: [{name: a, role: reader, jwt: {alg: HS256, secret: short}}]",
+			expectedErr: "clients[0]: jwt secret must be at least 32 bytes long",
+		},
+		{
+			name: "duplicate name",
+			data: "clients: [{name: a, role: reader, api_key_sha256: " + auth.HashAPIKey("a") + "}, " +
+				"{name: a, role: admin, api_key_sha256: " + auth.HashAPIKey("b") + "}]",
+			expectedErr: "clients[1]: duplicate name a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := auth.ParseClients([]byte(tc.data), "")
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, auth.RoleAdmin.Includes(auth.RoleEditor))
+	assert.True(t, auth.RoleEditor.Includes(auth.RoleEditor))
+	assert.False(t, auth.RoleReader.Includes(auth.RoleEditor))
+	assert.False(t, auth.Role("owner").Includes(auth.RoleReader))
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// jwtAlgs are accepted JWT signing algorithms, none is never accepted
+var jwtAlgs = []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}
+
+// Client is an API consumer with its role and credentials
+type Client struct {
+	Name string
+	Role Role
+
+	apiKeyHash []byte
+	jwtAlg     string
+	jwtKey     any
+}
+
+type clientsFile struct {
+	Clients []clientConfig `yaml:"clients"`
+}
+
+type clientConfig struct {
+	Name string `yaml:"name"`
+	Role Role   `yaml:"role"`
+	// APIKeySHA256 is hex sha256 of the API key, the key itself is never stored
+	APIKeySHA256 string     `yaml:"api_key_sha256"`
+	JWT          *jwtConfig `yaml:"jwt"`
+}
+
+type jwtConfig struct {
+	Alg string `yaml:"alg"`
+	// Secret verifies HS tokens
+	Secret string `yaml:"secret"`
+	// PublicKeyFile is PEM RSA public key verifying RS tokens, relative to the clients file
+	PublicKeyFile string `yaml:"public_key_file"`
+}
+
+// LoadClients reads clients from yaml file
+func LoadClients(path string) ([]*Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := ParseClients(data, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return clients, nil
+}
+
+// ParseClients parses clients yaml, key files are resolved against dir
+func ParseClients(data []byte, dir string) ([]*Client, error) {
+	var file clientsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Clients) == 0 {
+		return nil, errors.New("no clients configured")
+	}
+
+	clients := make([]*Client, 0, len(file.Clients))
+	names := make(map[string]struct{}, len(file.Clients))
+	apiKeys := make(map[string]struct{}, len(file.Clients))
+	for i, cfg := range file.Clients {
+		client, err := newClient(cfg, dir)
+		if err != nil {
+			return nil, fmt.Errorf("clients[%d]: %w", i, err)
+		}
+
+		if _, ok := names[client.Name]; ok {
+			return nil, fmt.Errorf("clients[%d]: duplicate name %s", i, client.Name)
+		}
+		names[client.Name] = struct{}{}
+		if client.apiKeyHash != nil {
+			if _, ok := apiKeys[string(client.apiKeyHash)]; ok {
+				return nil, fmt.Errorf("clients[%d]: api key is used by another client", i)
+			}
+			apiKeys[string(client.apiKeyHash)] = struct{}{}
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+func newClient(cfg clientConfig, dir string) (*Client, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if !cfg.Role.Valid() {
+		return nil, fmt.Errorf("role %q is not one of reader, editor, admin", cfg.Role)
+	}
+	if cfg.APIKeySHA256 == "" && cfg.JWT == nil {
+		return nil, errors.New("api_key_sha256 or jwt is required")
+	}
+
+	client := &Client{
+		Name: cfg.Name,
+		Role: cfg.Role,
+	}
+
+	if cfg.APIKeySHA256 != "" {
+		hash, err := hex.DecodeString(cfg.APIKeySHA256)
+		if err != nil || len(hash) != 32 {
+			return nil, errors.New("api_key_sha256 must be hex encoded sha256")
+		}
+		client.apiKeyHash = hash
+	}
+
+	if cfg.JWT != nil {
+		if !slices.Contains(jwtAlgs, cfg.JWT.Alg) {
+			return nil, fmt.Errorf("jwt alg %q is not supported", cfg.JWT.Alg)
+		}
+		client.jwtAlg = cfg.JWT.Alg
+
+		switch cfg.JWT.Alg[:2] {
+		case "HS":
+			if len(cfg.JWT.Secret) < 32 {
+				return nil, errors.New("jwt secret must be at least 32 bytes long")
+			}
+			client.jwtKey = []byte(cfg.JWT.Secret)
+		case "RS":
+			if cfg.JWT.PublicKeyFile == "" {
+				return nil, errors.New("jwt public_key_file is required for RS algorithms")
+			}
+			path := cfg.JWT.PublicKeyFile
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("jwt public key: %w", err)
+			}
+			client.jwtKey = key
+		}
+	}
+
+	return client, nil
+}
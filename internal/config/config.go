@@ -0,0 +1,462 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"avito_2023/internal/segment/slug"
+	"avito_2023/internal/tracing"
+)
+
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+type Config struct {
+	HTTP    HTTPConfig
+	DB      DBConfig
+	Log     LogConfig
+	TTL     TTLConfig
+	Slug    SlugConfig
+	Reports ReportsConfig
+	Auth    AuthConfig
+	Metrics MetricsConfig
+	Tracing TracingConfig
+}
+
+type HTTPConfig struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout bounds draining of in-flight requests and background workers
+	ShutdownTimeout time.Duration
+	// TrustedProxies is a comma separated list of proxies IPs or CIDRs allowed to set X-Forwarded-For
+	TrustedProxies string
+}
+
+// TrustedProxiesList returns trusted proxies, nil means client IP is always the connection address
+func (c HTTPConfig) TrustedProxiesList() []string {
+	if c.TrustedProxies == "" {
+		return nil
+	}
+
+	return strings.Split(c.TrustedProxies, ",")
+}
+
+type DBConfig struct {
+	Host            string
+	Port            string
+	User            string
+	Password        string
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DSN returns postgres connection string
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
+}
+
+type LogConfig struct {
+	Level string
+}
+
+// SlogLevel returns slog level matching configured log level
+func (c LogConfig) SlogLevel() slog.Level {
+	switch c.Level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type TTLConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+type SlugConfig struct {
+	Charset   string
+	MinLength int
+	MaxLength int
+	Case      string
+	// ReservedPrefixes is a comma separated list
+	ReservedPrefixes string
+}
+
+// Options returns slug policy options
+func (c SlugConfig) Options() slug.Options {
+	var reserved []string
+	if c.ReservedPrefixes != "" {
+		reserved = strings.Split(c.ReservedPrefixes, ",")
+	}
+
+	return slug.Options{
+		Charset:          c.Charset,
+		MinLength:        c.MinLength,
+		MaxLength:        c.MaxLength,
+		Case:             c.Case,
+		ReservedPrefixes: reserved,
+	}
+}
+
+type ReportsConfig struct {
+	// TTL is how long created reports are kept
+	TTL time.Duration
+}
+
+type AuthConfig struct {
+	// ClientsFile is yaml file with API clients, their roles and credentials
+	ClientsFile string
+	// Disabled lets every request through, meant for local development only
+	Disabled bool
+	// PurgeToken authorizes segment purge on top of admin role, purge is disabled when empty
+	PurgeToken string
+}
+
+type MetricsConfig struct {
+	// Disabled removes /metrics endpoint and instrumentation
+	Disabled bool
+	// Addr is listen address of /metrics, it is separate from the API, so it isn't exposed with it
+	Addr string
+	// MembersRefresh is how often active members per segment are recounted on scrape
+	MembersRefresh time.Duration
+}
+
+type TracingConfig struct {
+	// Exporter is one of none, stdout, otlp
+	Exporter    string
+	ServiceName string
+	// OTLPEndpoint is OTLP/HTTP collector host:port
+	OTLPEndpoint string
+	OTLPInsecure bool
+}
+
+// Options returns tracing options
+func (c TracingConfig) Options() tracing.Options {
+	return tracing.Options{
+		Exporter:    c.Exporter,
+		ServiceName: c.ServiceName,
+		Endpoint:    c.OTLPEndpoint,
+		Insecure:    c.OTLPInsecure,
+	}
+}
+
+// Default returns config used for settings missing in file, env and flags
+func Default() *Config {
+	slugDefaults := slug.DefaultOptions()
+
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:              ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		DB: DBConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Log: LogConfig{
+			Level: LogLevelInfo,
+		},
+		TTL: TTLConfig{
+			Interval:  30 * time.Second,
+			BatchSize: 500,
+		},
+		Slug: SlugConfig{
+			Charset:   slugDefaults.Charset,
+			MinLength: slugDefaults.MinLength,
+			MaxLength: slugDefaults.MaxLength,
+			Case:      slugDefaults.Case,
+		},
+		Reports: ReportsConfig{
+			TTL: 24 * time.Hour,
+		},
+		Metrics: MetricsConfig{
+			Addr:           ":9090",
+			MembersRefresh: time.Minute,
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "segments",
+		},
+	}
+}
+
+// Load builds config from defaults, env file, process env and command line flags,
+// every next source overrides the previous one. Empty values are treated as unset.
+// Env file is read from defaultPath unless -config flag is given, missing file is ignored.
+func Load(defaultPath string, args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", defaultPath, "path to env file")
+	keys := bind(fs, cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	// flags are applied last, so values bound by parsing are overwritten by file and env first
+	*cfg = *Default()
+
+	file, err := readEnvFile(*path)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if v := file[key]; v != "" {
+			if err := fs.Set(flagName(key), v); err != nil {
+				return nil, fmt.Errorf("%s in %s: %w", key, *path, err)
+			}
+		}
+	}
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			if err := fs.Set(flagName(key), v); err != nil {
+				return nil, fmt.Errorf("env %s: %w", key, err)
+			}
+		}
+	}
+	for name, v := range flags {
+		if name == "config" {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks config consistency
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.HTTP.Addr == "" {
+		errs = append(errs, errors.New("HTTP_ADDR is required"))
+	}
+	if !c.Metrics.Disabled && (c.Metrics.Addr == "" || c.Metrics.Addr == c.HTTP.Addr) {
+		errs = append(errs, errors.New("METRICS_ADDR is required and must differ from HTTP_ADDR unless METRICS_DISABLED is set"))
+	}
+	for key, d := range map[string]time.Duration{
+		"HTTP_READ_TIMEOUT":        c.HTTP.ReadTimeout,
+		"HTTP_READ_HEADER_TIMEOUT": c.HTTP.ReadHeaderTimeout,
+		"HTTP_WRITE_TIMEOUT":       c.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        c.HTTP.IdleTimeout,
+		"HTTP_SHUTDOWN_TIMEOUT":    c.HTTP.ShutdownTimeout,
+		"TTL_INTERVAL":             c.TTL.Interval,
+		"REPORTS_TTL":              c.Reports.TTL,
+		"METRICS_MEMBERS_REFRESH":  c.Metrics.MembersRefresh,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", key))
+		}
+	}
+
+	if c.DB.Host == "" {
+		errs = append(errs, errors.New("DB_HOST is required"))
+	}
+	if port, err := strconv.ParseUint(c.DB.Port, 10, 16); err != nil || port == 0 {
+		errs = append(errs, fmt.Errorf("DB_PORT %q is not a valid port", c.DB.Port))
+	}
+	if c.DB.User == "" {
+		errs = append(errs, errors.New("DB_USER is required"))
+	}
+	if c.DB.Name == "" {
+		errs = append(errs, errors.New("DB_NAME is required"))
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS must not be negative"))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS must not be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS"))
+	}
+	if c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("DB connection lifetimes must not be negative"))
+	}
+
+	switch c.Log.Level {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL %q is not one of debug, info, warn, error", c.Log.Level))
+	}
+
+	if c.TTL.BatchSize <= 0 {
+		errs = append(errs, errors.New("TTL_BATCH_SIZE must be positive"))
+	}
+
+	if _, err := slug.NewPolicy(c.Slug.Options()); err != nil {
+		errs = append(errs, err)
+	}
+
+	if !c.Auth.Disabled && c.Auth.ClientsFile == "" {
+		errs = append(errs, errors.New("AUTH_CLIENTS_FILE is required unless AUTH_DISABLED is set"))
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER %q is not one of none, stdout, otlp", c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
+
+// bind registers a flag for every setting and returns env keys of the settings
+func bind(fs *flag.FlagSet, c *Config) []string {
+	var keys []string
+	str := func(p *string, key, usage string) {
+		fs.StringVar(p, flagName(key), *p, usage)
+		keys = append(keys, key)
+	}
+	num := func(p *int, key, usage string) {
+		fs.IntVar(p, flagName(key), *p, usage)
+		keys = append(keys, key)
+	}
+	dur := func(p *time.Duration, key, usage string) {
+		fs.DurationVar(p, flagName(key), *p, usage)
+		keys = append(keys, key)
+	}
+	boolean := func(p *bool, key, usage string) {
+		fs.BoolVar(p, flagName(key), *p, usage)
+		keys = append(keys, key)
+	}
+
+	str(&c.HTTP.Addr, "HTTP_ADDR", "http listen address")
+	dur(&c.HTTP.ReadTimeout, "HTTP_READ_TIMEOUT", "http request read timeout")
+	dur(&c.HTTP.ReadHeaderTimeout, "HTTP_READ_HEADER_TIMEOUT", "http request headers read timeout")
+	dur(&c.HTTP.WriteTimeout, "HTTP_WRITE_TIMEOUT", "http response write timeout")
+	dur(&c.HTTP.IdleTimeout, "HTTP_IDLE_TIMEOUT", "http keep-alive idle timeout")
+	dur(&c.HTTP.ShutdownTimeout, "HTTP_SHUTDOWN_TIMEOUT", "graceful shutdown timeout")
+	str(&c.HTTP.TrustedProxies, "HTTP_TRUSTED_PROXIES", "comma separated proxies IPs or CIDRs allowed to set X-Forwarded-For")
+
+	str(&c.DB.Host, "DB_HOST", "postgres host")
+	str(&c.DB.Port, "DB_PORT", "postgres port")
+	str(&c.DB.User, "DB_USER", "postgres user")
+	str(&c.DB.Password, "DB_PASSWORD", "postgres password")
+	str(&c.DB.Name, "DB_NAME", "postgres database")
+	str(&c.DB.SSLMode, "DB_SSLMODE", "postgres sslmode")
+	num(&c.DB.MaxOpenConns, "DB_MAX_OPEN_CONNS", "max open db connections, 0 is unlimited")
+	num(&c.DB.MaxIdleConns, "DB_MAX_IDLE_CONNS", "max idle db connections")
+	dur(&c.DB.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME", "max db connection lifetime, 0 is unlimited")
+	dur(&c.DB.ConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME", "max db connection idle time, 0 is unlimited")
+
+	str(&c.Log.Level, "LOG_LEVEL", "log level: debug, info, warn, error")
+
+	dur(&c.TTL.Interval, "TTL_INTERVAL", "ttl worker run interval")
+	num(&c.TTL.BatchSize, "TTL_BATCH_SIZE", "ttl worker batch size")
+
+	str(&c.Slug.Charset, "SLUG_CHARSET", "allowed slug characters as regexp character class")
+	num(&c.Slug.MinLength, "SLUG_MIN_LENGTH", "min slug length")
+	num(&c.Slug.MaxLength, "SLUG_MAX_LENGTH", "max slug length, at most 50")
+	str(&c.Slug.Case, "SLUG_CASE", "case new slugs are brought to: keep, upper, lower")
+	str(&c.Slug.ReservedPrefixes, "SLUG_RESERVED_PREFIXES", "comma separated slug prefixes not allowed for segments")
+
+	dur(&c.Reports.TTL, "REPORTS_TTL", "how long created reports are kept")
+
+	str(&c.Auth.ClientsFile, "AUTH_CLIENTS_FILE", "yaml file with API clients, their roles and credentials")
+	boolean(&c.Auth.Disabled, "AUTH_DISABLED", "let every request through without authentication, for local development only")
+	str(&c.Auth.PurgeToken, "PURGE_TOKEN", "token authorizing segment purge on top of admin role, purge is disabled when empty")
+
+	boolean(&c.Metrics.Disabled, "METRICS_DISABLED", "disable /metrics endpoint and instrumentation")
+	str(&c.Metrics.Addr, "METRICS_ADDR", "/metrics listen address, separate from HTTP_ADDR")
+	dur(&c.Metrics.MembersRefresh, "METRICS_MEMBERS_REFRESH", "how often active members per segment are recounted on scrape")
+
+	str(&c.Tracing.Exporter, "TRACING_EXPORTER", "tracing exporter: none, stdout, otlp")
+	str(&c.Tracing.ServiceName, "TRACING_SERVICE_NAME", "service name reported with spans")
+	str(&c.Tracing.OTLPEndpoint, "TRACING_OTLP_ENDPOINT", "OTLP/HTTP collector host:port, defaults to OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318")
+	boolean(&c.Tracing.OTLPInsecure, "TRACING_OTLP_INSECURE", "send spans to the collector over plain http")
+
+	return keys
+}
+
+// flagName converts env key to flag name, e.g. HTTP_ADDR to http-addr
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseEnv(f)
+}
+
+// parseEnv reads KEY=VALUE lines, empty lines and # comments are skipped
+func parseEnv(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("env file line %d: missing =", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
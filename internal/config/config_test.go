@@ -0,0 +1,135 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avito_2023/internal/config"
+)
+
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeEnvFile(t, `
+# database
+DB_HOST=db.local
+DB_PORT=5433
+DB_USER=file-user
+DB_PASSWORD="secret value"
+DB_NAME=segments
+DB_MAX_OPEN_CONNS=50
+LOG_LEVEL=debug
+HTTP_ADDR=
+AUTH_DISABLED=true
+`)
+
+	t.Setenv("DB_USER", "env-user")
+	t.Setenv("HTTP_READ_TIMEOUT", "3s")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := config.Load(path, []string{"-log-level", "error", "-ttl-batch-size", "100"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "db.local", cfg.DB.Host)
+	assert.Equal(t, "5433", cfg.DB.Port)
+	assert.Equal(t, "env-user", cfg.DB.User)
+	assert.Equal(t, "secret value", cfg.DB.Password)
+	assert.Equal(t, 50, cfg.DB.MaxOpenConns)
+	assert.Equal(t, 10, cfg.DB.MaxIdleConns)
+	assert.Equal(t, ":8080", cfg.HTTP.Addr)
+	assert.Equal(t, 3*time.Second, cfg.HTTP.ReadTimeout)
+	assert.Equal(t, config.LogLevelError, cfg.Log.Level)
+	assert.Equal(t, 100, cfg.TTL.BatchSize)
+	assert.True(t, cfg.Auth.Disabled)
+	assert.Equal(t, "host=db.local port=5433 user=env-user password=secret value dbname=segments sslmode=disable", cfg.DB.DSN())
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+	path := writeEnvFile(t, "DB_USER=user\nDB_NAME=segments\nAUTH_CLIENTS_FILE=clients.yaml\n")
+
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "missing.env"), []string{"-config", path})
+	require.NoError(t, err)
+
+	assert.Equal(t, "user", cfg.DB.User)
+	assert.Equal(t, "segments", cfg.DB.Name)
+	assert.Equal(t, "clients.yaml", cfg.Auth.ClientsFile)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		file        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "missing db settings",
+			file:        "",
+			expectedErr: "DB_USER is required",
+		},
+		{
+			name:        "invalid duration",
+			file:        "DB_USER=user\nDB_NAME=segments\nHTTP_WRITE_TIMEOUT=long\n",
+			expectedErr: "HTTP_WRITE_TIMEOUT",
+		},
+		{
+			name:        "invalid log level",
+			file:        "DB_USER=user\nDB_NAME=segments\n",
+			args:        []string{"-log-level", "verbose"},
+			expectedErr: `LOG_LEVEL "verbose" is not one of debug, info, warn, error`,
+		},
+		{
+			name:        "idle connections exceed open connections",
+			file:        "DB_USER=user\nDB_NAME=segments\nDB_MAX_OPEN_CONNS=5\nDB_MAX_IDLE_CONNS=10\n",
+			expectedErr: "DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS",
+		},
+		{
+			name:        "invalid port",
+			file:        "DB_USER=user\nDB_NAME=segments\nDB_PORT=postgres\n",
+			expectedErr: `DB_PORT "postgres" is not a valid port`,
+		},
+		{
+			name:        "missing auth clients file",
+			file:        "DB_USER=user\nDB_NAME=segments\n",
+			expectedErr: "AUTH_CLIENTS_FILE is required unless AUTH_DISABLED is set",
+		},
+		{
+			name:        "metrics on api address",
+			file:        "DB_USER=user\nDB_NAME=segments\nAUTH_DISABLED=true\nMETRICS_ADDR=:8080\n",
+			expectedErr: "METRICS_ADDR is required and must differ from HTTP_ADDR unless METRICS_DISABLED is set",
+		},
+		{
+			name:        "invalid tracing exporter",
+			file:        "DB_USER=user\nDB_NAME=segments\nAUTH_DISABLED=true\nTRACING_EXPORTER=jaeger\n",
+			expectedErr: `TRACING_EXPORTER "jaeger" is not one of none, stdout, otlp`,
+		},
+		{
+			name:        "invalid env file",
+			file:        "DB_USER\n",
+			expectedErr: "env file line 1: missing =",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_NAME", "LOG_LEVEL", "AUTH_CLIENTS_FILE", "AUTH_DISABLED", "METRICS_ADDR", "TRACING_EXPORTER"} {
+				t.Setenv(key, "")
+			}
+
+			_, err := config.Load(writeEnvFile(t, tc.file), tc.args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
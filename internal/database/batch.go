@@ -0,0 +1,4 @@
+package database
+
+// InsertBatchSize keeps bulk inserts below postgres bind parameters limit
+const InsertBatchSize = 1000
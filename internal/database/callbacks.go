@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// StatementCallback returns a callback for statements of the gorm operation, e.g. query or raw
+type StatementCallback func(operation string) func(db *gorm.DB)
+
+// RegisterStatementCallbacks registers callbacks run before and after every statement gorm runs,
+// it is meant for plugins instrumenting queries. Callbacks are named <plugin>:before_<operation>
+// and <plugin>:after_<operation>.
+func RegisterStatementCallbacks(db *gorm.DB, plugin string, before, after StatementCallback) error {
+	type register func(name string, fn func(*gorm.DB)) error
+
+	// processors types are unexported, so their register methods are taken as values
+	cb := db.Callback()
+	processors := []struct {
+		operation     string
+		before, after register
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+
+	var errs []error
+	for _, p := range processors {
+		errs = append(errs,
+			p.before(plugin+":before_"+p.operation, before(p.operation)),
+			p.after(plugin+":after_"+p.operation, after(p.operation)),
+		)
+	}
+
+	return errors.Join(errs...)
+}
@@ -17,7 +17,8 @@ func WithDB(ctx context.Context, db *gorm.DB) context.Context {
 	return context.WithValue(ctx, dbKey, db)
 }
 
-// FromContext returns db stored in the context if exist, otherwise returns given db
+// FromContext returns db stored in the context if exist, otherwise returns given db,
+// either bound to the context
 func FromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
 	if ctx == nil {
 		return db.Session(&gorm.Session{Context: ctx})
@@ -25,5 +26,5 @@ func FromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
 	if stored, ok := ctx.Value(dbKey).(*gorm.DB); ok {
 		return stored.Session(&gorm.Session{Context: ctx})
 	}
-	return db
+	return db.WithContext(ctx)
 }
@@ -3,19 +3,154 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"net/http"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
 var (
 	ErrNotFound                           = errors.New("record not found")
-	ErrUpdateUserSegments_InvalidSegments = errors.New("invalid segments")
+	ErrUpdateUserSegments_UnknownSegments = errors.New("unknown segments")
+	ErrPurgeSegment_NotArchived           = errors.New("segment is not archived")
+	ErrCreateUser_AlreadyExists           = errors.New("user already exists")
+	ErrAddSegment_AlreadyExists           = errors.New("segment already exists")
 )
 
+// Code is a stable machine readable error code returned to clients
+type Code string
+
+const (
+	CodeValidation    Code = "validation_failed"
+	CodeNotFound      Code = "not_found"
+	CodeAlreadyExists Code = "already_exists"
+	CodeConflict      Code = "conflict"
+	CodeUnauthorized  Code = "unauthorized"
+	CodeForbidden     Code = "forbidden"
+	CodeInternal      Code = "internal_error"
+)
+
+// HTTPStatus returns http status matching the code
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeAlreadyExists, CodeConflict:
+		return http.StatusConflict
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgNumericOutOfRange   = "22003"
+	pgInvalidTextValue    = "22P02"
+)
+
+// Error is an error with a code and a message safe to show to clients, the cause is kept for logs only
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewError(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Classify maps err to a coded error, errors not known to be safe become internal ones with generic message
+func Classify(err error) *Error {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded
+	}
+
+	switch {
+	case IsRecordNotFoundError(err):
+		return &Error{Code: CodeNotFound, Message: "record not found", Err: err}
+	case IsUpdateUserSegmentsUnknownSegmentsErr(err):
+		return &Error{Code: CodeValidation, Message: ErrUpdateUserSegments_UnknownSegments.Error(), Err: err}
+	case IsPurgeSegmentNotArchivedErr(err):
+		return &Error{Code: CodeConflict, Message: ErrPurgeSegment_NotArchived.Error(), Err: err}
+	case IsCreateUserAlreadyExistsErr(err):
+		return &Error{Code: CodeAlreadyExists, Message: ErrCreateUser_AlreadyExists.Error(), Err: err}
+	case IsAddSegmentAlreadyExistsErr(err):
+		return &Error{Code: CodeAlreadyExists, Message: ErrAddSegment_AlreadyExists.Error(), Err: err}
+	case IsUniqueViolationErr(err):
+		return &Error{Code: CodeAlreadyExists, Message: "resource already exists", Err: err}
+	case isPgError(err, pgForeignKeyViolation) || errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Code: CodeConflict, Message: "resource is referenced by or references missing resource", Err: err}
+	case isPgError(err, pgNotNullViolation, pgCheckViolation, pgStringTooLong, pgNumericOutOfRange, pgInvalidTextValue) ||
+		errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return &Error{Code: CodeValidation, Message: "invalid value", Err: err}
+	default:
+		return &Error{Code: CodeInternal, Message: "internal error", Err: err}
+	}
+}
+
 func IsRecordNotFoundError(err error) bool {
 	return errors.Is(err, ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, sql.ErrNoRows)
 }
 
-func IsUpdateUserSegmentsInvalidSegmentsErr(err error) bool {
-	return errors.Is(err, ErrUpdateUserSegments_InvalidSegments)
+func IsUpdateUserSegmentsUnknownSegmentsErr(err error) bool {
+	return errors.Is(err, ErrUpdateUserSegments_UnknownSegments)
+}
+
+func IsPurgeSegmentNotArchivedErr(err error) bool {
+	return errors.Is(err, ErrPurgeSegment_NotArchived)
+}
+
+func IsCreateUserAlreadyExistsErr(err error) bool {
+	return errors.Is(err, ErrCreateUser_AlreadyExists)
+}
+
+func IsAddSegmentAlreadyExistsErr(err error) bool {
+	return errors.Is(err, ErrAddSegment_AlreadyExists)
+}
+
+// IsUniqueViolationErr reports whether err is caused by unique constraint violation
+func IsUniqueViolationErr(err error) bool {
+	return isPgError(err, pgUniqueViolation) || errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+func isPgError(err error, codes ...string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	for _, code := range codes {
+		if pgErr.Code == code {
+			return true
+		}
+	}
+
+	return false
 }
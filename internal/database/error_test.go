@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedCode    Code
+		expectedMessage string
+		expectedStatus  int
+	}{
+		{
+			name:            "coded error",
+			err:             fmt.Errorf("wrapped: %w", NewError(CodeConflict, "segment is busy")),
+			expectedCode:    CodeConflict,
+			expectedMessage: "segment is busy",
+			expectedStatus:  http.StatusConflict,
+		},
+		{
+			name:            "record not found",
+			err:             fmt.Errorf("get segment: %w", gorm.ErrRecordNotFound),
+			expectedCode:    CodeNotFound,
+			expectedMessage: "record not found",
+			expectedStatus:  http.StatusNotFound,
+		},
+		{
+			name:            "segment already exists",
+			err:             ErrAddSegment_AlreadyExists,
+			expectedCode:    CodeAlreadyExists,
+			expectedMessage: "segment already exists",
+			expectedStatus:  http.StatusConflict,
+		},
+		{
+			name:            "unique violation",
+			err:             &pgconn.PgError{Code: "23505", Message: `duplicate key value violates unique constraint "unique_segments_slug"`},
+			expectedCode:    CodeAlreadyExists,
+			expectedMessage: "resource already exists",
+			expectedStatus:  http.StatusConflict,
+		},
+		{
+			name:            "foreign key violation",
+			err:             &pgconn.PgError{Code: "23503"},
+			expectedCode:    CodeConflict,
+			expectedMessage: "resource is referenced by or references missing resource",
+			expectedStatus:  http.StatusConflict,
+		},
+		{
+			name:            "value too long",
+			err:             &pgconn.PgError{Code: "22001", Message: "value too long for type character varying(50)"},
+			expectedCode:    CodeValidation,
+			expectedMessage: "invalid value",
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "unknown error",
+			err:             errors.New(`pq: relation "segments" does not exist`),
+			expectedCode:    CodeInternal,
+			expectedMessage: "internal error",
+			expectedStatus:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			coded := Classify(tc.err)
+
+			assert.Equal(t, tc.expectedCode, coded.Code)
+			assert.Equal(t, tc.expectedMessage, coded.Message)
+			assert.Equal(t, tc.expectedStatus, coded.Code.HTTPStatus())
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+)
+
+// MethodHook observes repo method calls, e.g. for metrics or tracing. It returns the context the method
+// runs with and a func called with the method error when the method is done.
+type MethodHook func(ctx context.Context, method string) (context.Context, func(err error))
+
+var methodHooks []MethodHook
+
+// AddMethodHook registers hook for all repos, it must be called before repos are used
+func AddMethodHook(hook MethodHook) {
+	methodHooks = append(methodHooks, hook)
+}
+
+type methodKey struct{}
+
+// StartMethod marks ctx with repo method name, so queries of the method can be attributed to it,
+// and runs method hooks. Call the returned func with the method error when it's done.
+func StartMethod(ctx context.Context, method string) (context.Context, func(err error)) {
+	ctx = context.WithValue(ctx, methodKey{}, method)
+
+	ends := make([]func(error), 0, len(methodHooks))
+	for _, hook := range methodHooks {
+		var end func(error)
+		ctx, end = hook(ctx, method)
+		ends = append(ends, end)
+	}
+
+	return ctx, func(err error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+// MethodFromContext returns name of the repo method ctx belongs to, if any
+func MethodFromContext(ctx context.Context) (string, bool) {
+	method, ok := ctx.Value(methodKey{}).(string)
+	return method, ok
+}
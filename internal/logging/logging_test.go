@@ -0,0 +1,65 @@
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"avito_2023/internal/logging"
+)
+
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name     string
+		level    slog.Level
+		status   int
+		expected string
+	}{
+		{
+			name:     "request logged at info",
+			level:    slog.LevelInfo,
+			status:   http.StatusOK,
+			expected: `level=INFO msg=request method=GET path=/segment/AVITO_VOICE_MESSAGES route=/segment/:slug status=200`,
+		},
+		{
+			name:   "request skipped above info",
+			level:  slog.LevelError,
+			status: http.StatusOK,
+		},
+		{
+			name:     "server error logged at warn",
+			level:    slog.LevelWarn,
+			status:   http.StatusInternalServerError,
+			expected: `level=WARN msg=request method=GET path=/segment/AVITO_VOICE_MESSAGES route=/segment/:slug status=500`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			defaultLogger := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tc.level})))
+			defer slog.SetDefault(defaultLogger)
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(logging.Middleware())
+			r.GET("/segment/:slug", func(c *gin.Context) {
+				c.Status(tc.status)
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/segment/AVITO_VOICE_MESSAGES", nil))
+
+			if tc.expected == "" {
+				assert.Empty(t, buf.String())
+				return
+			}
+			assert.Contains(t, buf.String(), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"avito_2023/internal/ttl/worker"
+)
+
+// membersQueryTimeout bounds counting members on scrape
+const membersQueryTimeout = 5 * time.Second
+
+type MembersCounter interface {
+	CountActiveMembers(ctx context.Context) (map[string]uint, error)
+}
+
+// membersCollector reports active members of every segment. Counting members scans memberships,
+// so counts are cached and refreshed on scrape at most once per refresh interval.
+type membersCollector struct {
+	counter MembersCounter
+	refresh time.Duration
+	desc    *prometheus.Desc
+
+	mu          sync.Mutex
+	counts      map[string]uint
+	refreshedAt time.Time
+}
+
+// NewMembersCollector returns collector of segment_active_members gauge
+func NewMembersCollector(counter MembersCounter, refresh time.Duration) prometheus.Collector {
+	return &membersCollector{
+		counter: counter,
+		refresh: refresh,
+		desc: prometheus.NewDesc(
+			"segment_active_members",
+			"Active members of not archived segment.",
+			[]string{"segment"}, nil,
+		),
+	}
+}
+
+func (c *membersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *membersCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil || time.Since(c.refreshedAt) >= c.refresh {
+		ctx, cancel := context.WithTimeout(context.Background(), membersQueryTimeout)
+		counts, err := c.counter.CountActiveMembers(ctx)
+		cancel()
+		if err != nil {
+			// stale counts are reported until the next successful refresh
+			slog.Error("failed to count segments members", "error", err)
+		} else {
+			c.counts = counts
+			c.refreshedAt = time.Now()
+		}
+	}
+
+	for segment, count := range c.counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), segment)
+	}
+}
+
+type TTLStatusProvider interface {
+	Status() worker.Status
+}
+
+// NewTTLCollectors returns collectors of TTL worker lag, last success and processed memberships
+func NewTTLCollectors(provider TTLStatusProvider) []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "ttl_worker_lag_seconds",
+			Help: "Age of the oldest membership due to be activated or expired as of the last worker run.",
+		}, func() float64 {
+			// counted up to now, so the lag keeps growing while the worker is stuck
+			oldest := provider.Status().OldestDueAt
+			if oldest == nil {
+				return 0
+			}
+			return max(time.Since(*oldest), 0).Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "ttl_worker_last_success_timestamp_seconds",
+			Help: "Unix time of the last worker run which processed all due memberships without errors.",
+		}, func() float64 {
+			success := provider.Status().LastSuccessAt
+			if success == nil {
+				return 0
+			}
+			return float64(success.Unix())
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "ttl_worker_activated_total",
+			Help: "Scheduled memberships activated by TTL worker.",
+		}, func() float64 {
+			return float64(provider.Status().TotalActivated)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "ttl_worker_expired_total",
+			Help: "Memberships expired by TTL worker.",
+		}, func() float64 {
+			return float64(provider.Status().TotalExpired)
+		}),
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"avito_2023/internal/database"
+)
+
+const (
+	startKey = "metrics:start"
+
+	// unknownMethod labels statements issued outside of repo methods, e.g. by migrations
+	unknownMethod = "unknown"
+)
+
+// gormPlugin observes every statement gorm runs
+type gormPlugin struct {
+	m *Metrics
+}
+
+// GormPlugin returns gorm plugin observing statements duration and errors,
+// statements are attributed to the repo method found in their context
+func (m *Metrics) GormPlugin() gorm.Plugin {
+	return &gormPlugin{m: m}
+}
+
+func (p *gormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	return database.RegisterStatementCallbacks(db, p.Name(), p.before, p.after)
+}
+
+func (p *gormPlugin) before(string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+	}
+}
+
+func (p *gormPlugin) after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+
+		method := unknownMethod
+		if db.Statement.Context != nil {
+			if m, ok := database.MethodFromContext(db.Statement.Context); ok {
+				method = m
+			}
+		}
+
+		p.m.queryDuration.WithLabelValues(method, operation).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			p.m.queryErrors.WithLabelValues(method, operation).Inc()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"avito_2023/internal/database"
+)
+
+// unmatchedRoute labels requests not matching any route, so unknown paths don't blow up cardinality
+const unmatchedRoute = "unmatched"
+
+// Metrics keeps service prometheus collectors in its own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	queryErrors     *prometheus.CounterVec
+	methodDuration  *prometheus.HistogramVec
+	methodErrors    *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route and response status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "SQL statement duration by repo method and gorm operation.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"method", "operation"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Failed SQL statements by repo method and gorm operation, record not found is not counted.",
+		}, []string{"method", "operation"}),
+		methodDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "repo_method_duration_seconds",
+			Help:    "Repo method duration including all its statements.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		methodErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "repo_method_errors_total",
+			Help: "Repo method errors by error code.",
+		}, []string{"method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.queryDuration,
+		m.queryErrors,
+		m.methodDuration,
+		m.methodErrors,
+	)
+
+	return m
+}
+
+// Register adds collectors to the service registry
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+	var errs []error
+	for _, c := range cs {
+		errs = append(errs, m.registry.Register(c))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Middleware observes duration of every request labeled with its route template
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.requestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// MethodHook observes repo methods duration and errors, see database.AddMethodHook
+func (m *Metrics) MethodHook() database.MethodHook {
+	return func(ctx context.Context, method string) (context.Context, func(err error)) {
+		start := time.Now()
+
+		return ctx, func(err error) {
+			m.methodDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+			if err != nil {
+				m.methodErrors.WithLabelValues(method, string(database.Classify(err).Code)).Inc()
+			}
+		}
+	}
+}
+
+// Handler serves metrics in prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		Registry: m.registry,
+	})
+}
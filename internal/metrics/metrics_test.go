@@ -0,0 +1,150 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/metrics"
+	"avito_2023/internal/ttl/worker"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	m := metrics.New()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/segment/:slug", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/segment/AVITO_VOICE_MESSAGES", "/segment/AVITO_DISCOUNT_30", "/unknown/42"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/segment/:slug",status="204"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`)
+}
+
+func TestMethodHook(t *testing.T) {
+	m := metrics.New()
+	hook := m.MethodHook()
+
+	for _, err := range []error{nil, database.ErrNotFound, errors.New("connection refused")} {
+		_, end := hook(context.Background(), "segment.GetSegment")
+		end(err)
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `repo_method_duration_seconds_count{method="segment.GetSegment"} 3`)
+	assert.Contains(t, body, `repo_method_errors_total{code="not_found",method="segment.GetSegment"} 1`)
+	assert.Contains(t, body, `repo_method_errors_total{code="internal_error",method="segment.GetSegment"} 1`)
+}
+
+func TestGormPlugin(t *testing.T) {
+	m := metrics.New()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(m.GormPlugin()))
+
+	ctx, end := database.StartMethod(context.Background(), "segment.GetSegments")
+	var slugs []string
+	require.NoError(t, db.WithContext(ctx).Table("segments").Pluck("slug", &slugs).Error)
+	end(nil)
+	require.NoError(t, db.Exec("SELECT 1").Error)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `db_query_duration_seconds_count{method="segment.GetSegments",operation="query"} 1`)
+	assert.Contains(t, body, `db_query_duration_seconds_count{method="unknown",operation="raw"} 1`)
+}
+
+type membersCounter struct {
+	calls  int
+	counts map[string]uint
+	err    error
+}
+
+func (c *membersCounter) CountActiveMembers(context.Context) (map[string]uint, error) {
+	c.calls++
+	return c.counts, c.err
+}
+
+func TestMembersCollector(t *testing.T) {
+	m := metrics.New()
+
+	counter := &membersCounter{counts: map[string]uint{"AVITO_VOICE_MESSAGES": 3}}
+	require.NoError(t, m.Register(metrics.NewMembersCollector(counter, time.Hour)))
+
+	assert.Contains(t, scrape(t, m), `segment_active_members{segment="AVITO_VOICE_MESSAGES"} 3`)
+
+	// cached counts are reported until refresh interval passes
+	counter.counts = map[string]uint{"AVITO_VOICE_MESSAGES": 5}
+	assert.Contains(t, scrape(t, m), `segment_active_members{segment="AVITO_VOICE_MESSAGES"} 3`)
+	assert.Equal(t, 1, counter.calls)
+}
+
+func TestMembersCollector_StaleOnError(t *testing.T) {
+	m := metrics.New()
+
+	counter := &membersCounter{counts: map[string]uint{"AVITO_DISCOUNT_30": 2}}
+	require.NoError(t, m.Register(metrics.NewMembersCollector(counter, 0)))
+	assert.Contains(t, scrape(t, m), `segment_active_members{segment="AVITO_DISCOUNT_30"} 2`)
+
+	counter.err = errors.New("timeout")
+	assert.Contains(t, scrape(t, m), `segment_active_members{segment="AVITO_DISCOUNT_30"} 2`)
+	assert.Equal(t, 2, counter.calls)
+}
+
+type ttlStatus worker.Status
+
+func (s ttlStatus) Status() worker.Status {
+	return worker.Status(s)
+}
+
+func TestTTLCollectors(t *testing.T) {
+	m := metrics.New()
+
+	oldestDueAt := time.Now().Add(-time.Hour)
+	lastSuccessAt := time.Unix(1692100000, 0)
+	require.NoError(t, m.Register(metrics.NewTTLCollectors(ttlStatus{
+		OldestDueAt:    &oldestDueAt,
+		LastSuccessAt:  &lastSuccessAt,
+		TotalActivated: 4,
+		TotalExpired:   7,
+	})...))
+
+	body := scrape(t, m)
+	assert.Regexp(t, `ttl_worker_lag_seconds 360\d\.`, body)
+	assert.Contains(t, body, "ttl_worker_last_success_timestamp_seconds 1.6921e+09")
+	assert.Contains(t, body, "ttl_worker_activated_total 4")
+	assert.Contains(t, body, "ttl_worker_expired_total 7")
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"avito_2023/internal/auth"
+	"avito_2023/internal/database"
+	"avito_2023/internal/report/model"
+	"avito_2023/internal/report/repo"
+	"avito_2023/internal/report/storage"
+	"avito_2023/internal/response"
+)
+
+const (
+	reportExt = ".csv"
+
+	contentTypeCSV = "text/csv; charset=utf-8"
+)
+
+type Handler struct {
+	repo    repo.Repo
+	storage storage.Storage
+}
+
+// @Summary Create History Report
+// @Tags report
+// @Description Create CSV report with segments history for specified month and return link to it
+// @Description relative to the service, reports are deleted after REPORTS_TTL
+// @Accept json
+// @Produce json
+// @Param body body CreateReportRequest true "report period and optional user"
+// @Success 201
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /report [post]
+func (h *Handler) createReport(c *gin.Context) {
+	var body CreateReportRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	id, err := newReportID()
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	// history is written to storage as it is read, nothing is saved if there is none
+	if err := h.storage.Save(c.Request.Context(), id, func(w io.Writer) error {
+		return h.writeReport(c.Request.Context(), w, &body)
+	}); err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("history for %d-%02d not found", body.Year, body.Month))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": reportPath(id)})
+}
+
+// @Summary Get History Report
+// @Tags report
+// @Description Download CSV report created by POST /report
+// @Produce text/csv
+// @Param file path string true "report file name"
+// @Success 200
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /reports/{file} [get]
+func (h *Handler) getReport(c *gin.Context) {
+	var uri GetReportUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	id, ok := strings.CutSuffix(uri.File, reportExt)
+	if !ok {
+		response.NotFound(c, fmt.Sprintf("report %s not found", uri.File))
+		return
+	}
+
+	report, err := h.storage.Open(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidID) {
+			response.NotFound(c, fmt.Sprintf("report %s not found", uri.File))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+	defer report.Close()
+
+	c.DataFromReader(http.StatusOK, -1, contentTypeCSV, report, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, uri.File),
+	})
+}
+
+func (h *Handler) writeReport(ctx context.Context, out io.Writer, body *CreateReportRequest) error {
+	w := csv.NewWriter(out)
+	w.Comma = ';'
+
+	if err := w.Write([]string{"user_id", "segment", "operation", "datetime"}); err != nil {
+		return err
+	}
+	if err := h.repo.ScanHistory(ctx, body.Month, body.Year, body.UserID, func(record *model.HistoryRecord) error {
+		return w.Write([]string{
+			strconv.FormatUint(uint64(record.UserID), 10),
+			record.Slug,
+			record.Operation,
+			record.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}); err != nil {
+		return err
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+func newReportID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// reportPath returns link to the report relative to the service, Host header isn't trusted to build absolute one
+func reportPath(id string) string {
+	return "/reports/" + id + reportExt
+}
+
+func NewHandler(repo repo.Repo, storage storage.Storage) *Handler {
+	return &Handler{
+		repo:    repo,
+		storage: storage,
+	}
+}
+
+func Route(r *gin.Engine, h *Handler, guard auth.Guard) {
+	r.POST("/report", guard.Require(auth.RoleReader), h.createReport)
+	r.GET("/reports/:file", guard.Require(auth.RoleReader), h.getReport)
+}
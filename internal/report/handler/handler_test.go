@@ -0,0 +1,236 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"avito_2023/internal/auth"
+	"avito_2023/internal/database"
+	"avito_2023/internal/report/handler"
+	"avito_2023/internal/report/model"
+	"avito_2023/internal/report/repo/mocks"
+	"avito_2023/internal/report/storage"
+	uModel "avito_2023/internal/user/model"
+)
+
+type Suite struct {
+	suite.Suite
+
+	r       *gin.Engine
+	dir     string
+	repo    *mocks.RepoMock
+	handler *handler.Handler
+}
+
+func (s *Suite) SetupSuite() {
+	s.dir = s.T().TempDir()
+	st, err := storage.NewLocalStorage(s.dir)
+	s.Require().NoError(err)
+
+	s.repo = &mocks.RepoMock{}
+	s.handler = handler.NewHandler(s.repo, st)
+
+	gin.SetMode(gin.TestMode)
+	s.r = gin.Default()
+
+	handler.Route(s.r, s.handler, auth.Disabled{})
+}
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, &Suite{})
+}
+
+func (s *Suite) TestCreateReport() {
+	at := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		inputBody    map[string]interface{}
+		mockFc       func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error
+		expectedCode int
+		expectedErr  string
+		expectedCSV  string
+	}{
+		{
+			name: "create report",
+			inputBody: map[string]interface{}{
+				"month": 8,
+				"year":  2023,
+			},
+			mockFc: scanHistory(
+				&model.HistoryRecord{UserID: 1000, Slug: "test-slug-1", Operation: uModel.OperationAdd, CreatedAt: at},
+				&model.HistoryRecord{UserID: 1002, Slug: "test-slug-2", Operation: uModel.OperationRemove, CreatedAt: at.Add(time.Hour)},
+			),
+			expectedCode: http.StatusCreated,
+			expectedCSV: "user_id;segment;operation;datetime\n" +
+				"1000;test-slug-1;add;2023-08-15T12:30:00Z\n" +
+				"1002;test-slug-2;remove;2023-08-15T13:30:00Z\n",
+		},
+		{
+			name: "create report for user",
+			inputBody: map[string]interface{}{
+				"month":   8,
+				"year":    2023,
+				"user_id": 1000,
+			},
+			mockFc: func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+				if userID == nil || *userID != 1000 {
+					return fmt.Errorf("unexpected user filter")
+				}
+				return fn(&model.HistoryRecord{UserID: 1000, Slug: "test-slug-1", Operation: uModel.OperationAdd, CreatedAt: at})
+			},
+			expectedCode: http.StatusCreated,
+			expectedCSV: "user_id;segment;operation;datetime\n" +
+				"1000;test-slug-1;add;2023-08-15T12:30:00Z\n",
+		},
+		{
+			name: "invalid request body",
+			inputBody: map[string]interface{}{
+				"wrong": "wrong",
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid request body (month)",
+			inputBody: map[string]interface{}{
+				"month": 13,
+				"year":  2023,
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "history not found",
+			inputBody: map[string]interface{}{
+				"month": 8,
+				"year":  2023,
+			},
+			mockFc: func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+				return database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "history for 2023-08 not found",
+		},
+		{
+			name: "failed to get history from db",
+			inputBody: map[string]interface{}{
+				"month": 8,
+				"year":  2023,
+			},
+			mockFc: func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+				// the part written before the failure isn't saved
+				if err := fn(&model.HistoryRecord{UserID: 1000, Slug: "test-slug-1", Operation: uModel.OperationAdd, CreatedAt: at}); err != nil {
+					return err
+				}
+				return fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.ScanHistoryFunc = tc.mockFc
+			}
+
+			saved, err := os.ReadDir(s.dir)
+			require.NoError(t, err)
+
+			b, _ := json.Marshal(tc.inputBody)
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/report", bytes.NewBuffer(b))
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			if tc.expectedCode != http.StatusCreated {
+				files, err := os.ReadDir(s.dir)
+				require.NoError(t, err)
+				assert.Len(t, files, len(saved), "failed report must not be saved")
+			}
+
+			if tc.expectedErr != "" {
+				assert.Contains(t, res.Body.String(), tc.expectedErr)
+			}
+
+			if tc.expectedCSV != "" {
+				var resp struct {
+					URL string `json:"url"`
+				}
+				require.NoError(t, json.Unmarshal(res.Body.Bytes(), &resp))
+
+				require.Regexp(t, `^/reports/[0-9a-f]{32}\.csv$`, resp.URL)
+
+				res := httptest.NewRecorder()
+				req, _ := http.NewRequest(http.MethodGet, resp.URL, nil)
+				s.r.ServeHTTP(res, req)
+
+				assert.Equal(t, http.StatusOK, res.Code)
+				assert.Equal(t, tc.expectedCSV, res.Body.String())
+			}
+		})
+	}
+}
+
+func scanHistory(records ...*model.HistoryRecord) func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+	return func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (s *Suite) TestGetReport() {
+	testCases := []struct {
+		name         string
+		inputFile    string
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:         "report not found",
+			inputFile:    "0123456789abcdef0123456789abcdef.csv",
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "report 0123456789abcdef0123456789abcdef.csv not found",
+		},
+		{
+			name:         "invalid report id",
+			inputFile:    "..%2Fsecret.csv",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "invalid report extension",
+			inputFile:    "0123456789abcdef0123456789abcdef.json",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/reports/"+tc.inputFile, nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedErr != "" {
+				assert.Contains(t, res.Body.String(), tc.expectedErr)
+			}
+		})
+	}
+}
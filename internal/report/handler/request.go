@@ -0,0 +1,11 @@
+package handler
+
+type CreateReportRequest struct {
+	Month  uint  `json:"month" binding:"required,min=1,max=12"`
+	Year   uint  `json:"year" binding:"required"`
+	UserID *uint `json:"user_id"`
+}
+
+type GetReportUri struct {
+	File string `uri:"file" binding:"required"`
+}
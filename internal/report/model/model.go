@@ -0,0 +1,12 @@
+package model
+
+import (
+	"time"
+)
+
+type HistoryRecord struct {
+	UserID    uint      `gorm:"user_id"`
+	Slug      string    `gorm:"slug"`
+	Operation string    `gorm:"operation"`
+	CreatedAt time.Time `gorm:"created_at"`
+}
@@ -0,0 +1,21 @@
+package repo
+
+import (
+	"context"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/report/model"
+)
+
+// instrumentedRepo attributes queries of every repo method to it and reports the method
+// to hooks registered with database.AddMethodHook
+type instrumentedRepo struct {
+	repo Repo
+}
+
+func (r *instrumentedRepo) ScanHistory(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) (err error) {
+	ctx, end := database.StartMethod(ctx, "report.ScanHistory")
+	defer func() { end(err) }()
+
+	return r.repo.ScanHistory(ctx, month, year, userID, fn)
+}
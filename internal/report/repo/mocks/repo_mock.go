@@ -0,0 +1,101 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"avito_2023/internal/report/model"
+	"avito_2023/internal/report/repo"
+	"context"
+	"sync"
+)
+
+// Ensure, that RepoMock does implement repo.Repo.
+// If this is not the case, regenerate this file with moq.
+var _ repo.Repo = &RepoMock{}
+
+// RepoMock is a mock implementation of repo.Repo.
+//
+//	func TestSomethingThatUsesRepo(t *testing.T) {
+//
+//		// make and configure a mocked repo.Repo
+//		mockedRepo := &RepoMock{
+//			ScanHistoryFunc: func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+//				panic("mock out the ScanHistory method")
+//			},
+//		}
+//
+//		// use mockedRepo in code that requires repo.Repo
+//		// and then make assertions.
+//
+//	}
+type RepoMock struct {
+	// ScanHistoryFunc mocks the ScanHistory method.
+	ScanHistoryFunc func(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ScanHistory holds details about calls to the ScanHistory method.
+		ScanHistory []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Month is the month argument value.
+			Month uint
+			// Year is the year argument value.
+			Year uint
+			// UserID is the userID argument value.
+			UserID *uint
+			// Fn is the fn argument value.
+			Fn func(record *model.HistoryRecord) error
+		}
+	}
+	lockScanHistory sync.RWMutex
+}
+
+// ScanHistory calls ScanHistoryFunc.
+func (mock *RepoMock) ScanHistory(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+	if mock.ScanHistoryFunc == nil {
+		panic("RepoMock.ScanHistoryFunc: method is nil but Repo.ScanHistory was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Month  uint
+		Year   uint
+		UserID *uint
+		Fn     func(record *model.HistoryRecord) error
+	}{
+		Ctx:    ctx,
+		Month:  month,
+		Year:   year,
+		UserID: userID,
+		Fn:     fn,
+	}
+	mock.lockScanHistory.Lock()
+	mock.calls.ScanHistory = append(mock.calls.ScanHistory, callInfo)
+	mock.lockScanHistory.Unlock()
+	return mock.ScanHistoryFunc(ctx, month, year, userID, fn)
+}
+
+// ScanHistoryCalls gets all the calls that were made to ScanHistory.
+// Check the length with:
+//
+//	len(mockedRepo.ScanHistoryCalls())
+func (mock *RepoMock) ScanHistoryCalls() []struct {
+	Ctx    context.Context
+	Month  uint
+	Year   uint
+	UserID *uint
+	Fn     func(record *model.HistoryRecord) error
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Month  uint
+		Year   uint
+		UserID *uint
+		Fn     func(record *model.HistoryRecord) error
+	}
+	mock.lockScanHistory.RLock()
+	calls = mock.calls.ScanHistory
+	mock.lockScanHistory.RUnlock()
+	return calls
+}
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/report/model"
+	uModel "avito_2023/internal/user/model"
+)
+
+//go:generate moq --out mocks/repo_mock.go --pkg=mocks . Repo
+
+type Repo interface {
+	// ScanHistory - pass segments history for all users (or specified user) for the month to fn record by record,
+	// ordered by time, so the month is never held in memory
+	ScanHistory(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error
+}
+
+type repo struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repo {
+	return &instrumentedRepo{
+		repo: &repo{
+			db: db,
+		},
+	}
+}
+
+func (r *repo) ScanHistory(ctx context.Context, month uint, year uint, userID *uint, fn func(record *model.HistoryRecord) error) error {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	from := time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	query := db.Model(&uModel.SegmentEventDB{}).
+		Select("user_id", "segment_slug AS slug", "operation", "created_at").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		// history of erased users is pseudonymized and left out of reports
+		Where("user_id IS NOT NULL")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	rows, err := query.Order("created_at, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var record model.HistoryRecord
+		if err := rows.Scan(&record.UserID, &record.Slug, &record.Operation, &record.CreatedAt); err != nil {
+			return err
+		}
+		found = true
+
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !found {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"avito_2023/internal/report/storage"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Ensure, that StorageMock does implement storage.Storage.
+// If this is not the case, regenerate this file with moq.
+var _ storage.Storage = &StorageMock{}
+
+// StorageMock is a mock implementation of storage.Storage.
+//
+//	func TestSomethingThatUsesStorage(t *testing.T) {
+//
+//		// make and configure a mocked storage.Storage
+//		mockedStorage := &StorageMock{
+//			DeleteExpiredFunc: func(ctx context.Context, before time.Time) (int, error) {
+//				panic("mock out the DeleteExpired method")
+//			},
+//			OpenFunc: func(ctx context.Context, id string) (io.ReadCloser, error) {
+//				panic("mock out the Open method")
+//			},
+//			SaveFunc: func(ctx context.Context, id string, write func(w io.Writer) error) error {
+//				panic("mock out the Save method")
+//			},
+//		}
+//
+//		// use mockedStorage in code that requires storage.Storage
+//		// and then make assertions.
+//
+//	}
+type StorageMock struct {
+	// DeleteExpiredFunc mocks the DeleteExpired method.
+	DeleteExpiredFunc func(ctx context.Context, before time.Time) (int, error)
+
+	// OpenFunc mocks the Open method.
+	OpenFunc func(ctx context.Context, id string) (io.ReadCloser, error)
+
+	// SaveFunc mocks the Save method.
+	SaveFunc func(ctx context.Context, id string, write func(w io.Writer) error) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// DeleteExpired holds details about calls to the DeleteExpired method.
+		DeleteExpired []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Before is the before argument value.
+			Before time.Time
+		}
+		// Open holds details about calls to the Open method.
+		Open []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// Save holds details about calls to the Save method.
+		Save []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Write is the write argument value.
+			Write func(w io.Writer) error
+		}
+	}
+	lockDeleteExpired sync.RWMutex
+	lockOpen          sync.RWMutex
+	lockSave          sync.RWMutex
+}
+
+// DeleteExpired calls DeleteExpiredFunc.
+func (mock *StorageMock) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	if mock.DeleteExpiredFunc == nil {
+		panic("StorageMock.DeleteExpiredFunc: method is nil but Storage.DeleteExpired was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Before time.Time
+	}{
+		Ctx:    ctx,
+		Before: before,
+	}
+	mock.lockDeleteExpired.Lock()
+	mock.calls.DeleteExpired = append(mock.calls.DeleteExpired, callInfo)
+	mock.lockDeleteExpired.Unlock()
+	return mock.DeleteExpiredFunc(ctx, before)
+}
+
+// DeleteExpiredCalls gets all the calls that were made to DeleteExpired.
+// Check the length with:
+//
+//	len(mockedStorage.DeleteExpiredCalls())
+func (mock *StorageMock) DeleteExpiredCalls() []struct {
+	Ctx    context.Context
+	Before time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Before time.Time
+	}
+	mock.lockDeleteExpired.RLock()
+	calls = mock.calls.DeleteExpired
+	mock.lockDeleteExpired.RUnlock()
+	return calls
+}
+
+// Open calls OpenFunc.
+func (mock *StorageMock) Open(ctx context.Context, id string) (io.ReadCloser, error) {
+	if mock.OpenFunc == nil {
+		panic("StorageMock.OpenFunc: method is nil but Storage.Open was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockOpen.Lock()
+	mock.calls.Open = append(mock.calls.Open, callInfo)
+	mock.lockOpen.Unlock()
+	return mock.OpenFunc(ctx, id)
+}
+
+// OpenCalls gets all the calls that were made to Open.
+// Check the length with:
+//
+//	len(mockedStorage.OpenCalls())
+func (mock *StorageMock) OpenCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockOpen.RLock()
+	calls = mock.calls.Open
+	mock.lockOpen.RUnlock()
+	return calls
+}
+
+// Save calls SaveFunc.
+func (mock *StorageMock) Save(ctx context.Context, id string, write func(w io.Writer) error) error {
+	if mock.SaveFunc == nil {
+		panic("StorageMock.SaveFunc: method is nil but Storage.Save was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		ID    string
+		Write func(w io.Writer) error
+	}{
+		Ctx:   ctx,
+		ID:    id,
+		Write: write,
+	}
+	mock.lockSave.Lock()
+	mock.calls.Save = append(mock.calls.Save, callInfo)
+	mock.lockSave.Unlock()
+	return mock.SaveFunc(ctx, id, write)
+}
+
+// SaveCalls gets all the calls that were made to Save.
+// Check the length with:
+//
+//	len(mockedStorage.SaveCalls())
+func (mock *StorageMock) SaveCalls() []struct {
+	Ctx   context.Context
+	ID    string
+	Write func(w io.Writer) error
+} {
+	var calls []struct {
+		Ctx   context.Context
+		ID    string
+		Write func(w io.Writer) error
+	}
+	mock.lockSave.RLock()
+	calls = mock.calls.Save
+	mock.lockSave.RUnlock()
+	return calls
+}
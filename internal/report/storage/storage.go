@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	reportExt = ".csv"
+	tmpExt    = ".tmp"
+
+	// cleanupInterval bounds how long expired reports outlive their TTL
+	cleanupInterval = 10 * time.Minute
+)
+
+var (
+	ErrNotFound  = errors.New("report not found")
+	ErrInvalidID = errors.New("invalid report id")
+)
+
+var idRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+//go:generate moq --out mocks/storage_mock.go --pkg=mocks . Storage
+
+type Storage interface {
+	// Save - save report with specified id written by write, nothing is saved if write fails
+	Save(ctx context.Context, id string, write func(w io.Writer) error) error
+
+	// Open - open report with specified id
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+
+	// DeleteExpired - delete reports saved before the time, returns the number of deleted reports
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
+
+type localStorage struct {
+	dir string
+}
+
+// NewLocalStorage creates a storage keeping reports as csv files in the given directory
+func NewLocalStorage(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &localStorage{
+		dir: dir,
+	}, nil
+}
+
+func (s *localStorage) Save(_ context.Context, id string, write func(w io.Writer) error) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, id+".*"+tmpExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localStorage) Open(_ context.Context, id string) (io.ReadCloser, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// DeleteExpired also deletes temporary files left by saves interrupted before the time
+func (s *localStorage) DeleteExpired(_ context.Context, before time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		id, ok := strings.CutSuffix(name, reportExt)
+		report := ok && idRegexp.MatchString(id)
+		if !report && !strings.HasSuffix(name, tmpExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !info.ModTime().Before(before) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		if report {
+			deleted++
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+func (s *localStorage) path(id string) (string, error) {
+	if !idRegexp.MatchString(id) {
+		return "", ErrInvalidID
+	}
+
+	return filepath.Join(s.dir, id+reportExt), nil
+}
+
+// RunCleanup deletes reports older than ttl until ctx is done
+func RunCleanup(ctx context.Context, s Storage, ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl, cleanupInterval))
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.DeleteExpired(ctx, time.Now().Add(-ttl))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete expired reports", "error", err)
+		}
+		if deleted > 0 {
+			slog.InfoContext(ctx, "deleted expired reports", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avito_2023/internal/report/storage"
+)
+
+const (
+	oldID = "0123456789abcdef0123456789abcdef"
+	newID = "fedcba9876543210fedcba9876543210"
+)
+
+func save(t *testing.T, s storage.Storage, id, content string) {
+	t.Helper()
+
+	require.NoError(t, s.Save(context.Background(), id, func(w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	}))
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	s, err := storage.NewLocalStorage(dir)
+	require.NoError(t, err)
+
+	save(t, s, oldID, "user_id;segment\n")
+
+	r, err := s.Open(context.Background(), oldID)
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "user_id;segment\n", string(content))
+
+	// failed write leaves nothing behind
+	err = s.Save(context.Background(), newID, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "user_id;segment\n")
+		return errors.New("something went wrong")
+	})
+	assert.EqualError(t, err, "something went wrong")
+	_, err = s.Open(context.Background(), newID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestDeleteExpired(t *testing.T) {
+	dir := t.TempDir()
+	s, err := storage.NewLocalStorage(dir)
+	require.NoError(t, err)
+
+	save(t, s, oldID, "old")
+	save(t, s, newID, "new")
+	for _, name := range []string{"notes.txt", oldID + ".123.tmp"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{oldID + ".csv", oldID + ".123.tmp", "notes.txt"} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), old, old))
+	}
+
+	deleted, err := s.DeleteExpired(context.Background(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = s.Open(context.Background(), oldID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	r, err := s.Open(context.Background(), newID)
+	require.NoError(t, err)
+	r.Close()
+
+	// only reports and leftovers of interrupted saves are deleted
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.ElementsMatch(t, []string{newID + ".csv", "notes.txt"}, names)
+}
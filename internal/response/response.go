@@ -0,0 +1,71 @@
+package response
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+
+	"avito_2023/internal/database"
+)
+
+// errorCodeKey keeps code of the written error in gin context
+const errorCodeKey = "response.error_code"
+
+// ErrorResponse is the envelope of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	// Code is stable and meant for machines, message may change
+	Code    database.Code `json:"code" swaggertype:"string" example:"not_found"`
+	Message string        `json:"message" example:"segment AVITO_VOICE_MESSAGES not found"`
+}
+
+// Error writes err in the error envelope with status matching its code.
+// Errors without a code are logged and reported as internal without details.
+func Error(c *gin.Context, err error) {
+	ErrorWith(c, err, nil)
+}
+
+// ErrorWith writes err in the error envelope next to the given fields
+func ErrorWith(c *gin.Context, err error, fields gin.H) {
+	coded := database.Classify(err)
+	if coded.Code == database.CodeInternal {
+		slog.ErrorContext(c.Request.Context(), "request failed",
+			"method", c.Request.Method, "route", c.FullPath(), "error", err)
+	}
+
+	c.Set(errorCodeKey, coded.Code)
+
+	body := gin.H{}
+	for k, v := range fields {
+		body[k] = v
+	}
+	body["error"] = ErrorBody{
+		Code:    coded.Code,
+		Message: coded.Message,
+	}
+
+	c.JSON(coded.Code.HTTPStatus(), body)
+}
+
+// Validation writes validation error with the given message
+func Validation(c *gin.Context, message string) {
+	Error(c, database.NewError(database.CodeValidation, message))
+}
+
+// NotFound writes not found error with the given message
+func NotFound(c *gin.Context, message string) {
+	Error(c, database.NewError(database.CodeNotFound, message))
+}
+
+// ErrorCode returns code of the error written to the response, if any
+func ErrorCode(c *gin.Context) (database.Code, bool) {
+	code, ok := c.Get(errorCodeKey)
+	if !ok {
+		return "", false
+	}
+
+	return code.(database.Code), true
+}
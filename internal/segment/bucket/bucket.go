@@ -0,0 +1,40 @@
+package bucket
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// Buckets is the number of buckets users are spread across, one per percent
+const Buckets = 100
+
+// Bucket returns the stable bucket of the user for the segment salt.
+// It must stay in sync with Expr, which computes the same value in SQL.
+func Bucket(userID uint, salt string) uint {
+	sum := md5.Sum([]byte(salt + ":" + strconv.FormatUint(uint64(userID), 10)))
+	return uint(binary.BigEndian.Uint32(sum[:4]) % Buckets)
+}
+
+// Contains reports whether the user falls into the given percentage of the segment
+func Contains(userID uint, salt string, percentage uint) bool {
+	return Bucket(userID, salt) < percentage
+}
+
+// Expr returns the SQL expression computing Bucket for the given user id and salt expressions
+func Expr(userID, salt string) string {
+	return fmt.Sprintf("(('x' || substr(md5(%s || ':' || %s::text), 1, 8))::bit(32)::bigint %% %d)", salt, userID, Buckets)
+}
+
+// NewSalt generates a random salt for a new segment
+func NewSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,60 @@
+package bucket_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"avito_2023/internal/segment/bucket"
+)
+
+func TestBucket(t *testing.T) {
+	testCases := []struct {
+		name     string
+		userID   uint
+		salt     string
+		expected uint
+	}{
+		// expected values are md5("<salt>:<user_id>")[:8] as hex number mod 100, the same as in SQL
+		{name: "short salt", userID: 1000, salt: "salt", expected: 96},
+		{name: "generated salt", userID: 1002, salt: "0123456789abcdef0123456789abcdef", expected: 68},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, bucket.Bucket(tc.userID, tc.salt))
+			assert.True(t, bucket.Contains(tc.userID, tc.salt, tc.expected+1))
+			assert.False(t, bucket.Contains(tc.userID, tc.salt, tc.expected))
+		})
+	}
+}
+
+func TestContainsDistribution(t *testing.T) {
+	const users = 100000
+
+	salt, err := bucket.NewSalt()
+	assert.NoError(t, err)
+
+	var in10, in50 int
+	for id := uint(1); id <= users; id++ {
+		if bucket.Contains(id, salt, 10) {
+			in10++
+		}
+		if bucket.Contains(id, salt, 50) {
+			in50++
+			continue
+		}
+		// ramping up never drops users already in the segment
+		assert.False(t, bucket.Contains(id, salt, 10))
+	}
+
+	assert.InDelta(t, users/10, in10, users/100)
+	assert.InDelta(t, users/2, in50, users/100)
+}
+
+func TestExpr(t *testing.T) {
+	assert.Equal(t,
+		"(('x' || substr(md5(segments.salt || ':' || users.id::text), 1, 8))::bit(32)::bigint % 100)",
+		bucket.Expr("users.id", "segments.salt"),
+	)
+}
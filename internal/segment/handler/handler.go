@@ -1,43 +1,80 @@
 package handler
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	aModel "avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/recorder"
+	"avito_2023/internal/auth"
 	"avito_2023/internal/database"
+	"avito_2023/internal/response"
+	"avito_2023/internal/segment/model"
 	"avito_2023/internal/segment/repo"
+	"avito_2023/internal/segment/slug"
 )
 
+// PurgeTokenHeader carries the token authorizing segment purge
+const PurgeTokenHeader = "X-Purge-Token"
+
+var defaultTTLMessage = fmt.Sprintf("default_ttl_seconds must be at most %d", model.MaxTTLSeconds)
+
 type Handler struct {
-	repo repo.Repo
+	repo       repo.Repo
+	slugs      *slug.Policy
+	purgeToken string
 }
 
 // @Summary Add Segment
 // @Tags segment
-// @Description Add new segment with specified slug
+// @Description Add new segment with specified slug, description, owner team, auto percentage and default membership TTL,
+// @Description slug is checked against the slug policy and brought to its case
 // @Accept json
 // @Produce json
-// @Param body body AddSegmentRequest true "segment slug"
+// @Param body body AddSegmentRequest true "segment info"
 // @Success 201
-// @Failure 400
-// @Failure 500
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /segment/add [post]
 func (h *Handler) addSegment(c *gin.Context) {
 	var body AddSegmentRequest
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Validation(c, err.Error())
 		return
 	}
+	recorder.Segments(c, body.Slug)
 
 	if body.Percentage < 0 || body.Percentage > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid percentage"})
+		response.Validation(c, "invalid percentage")
+		return
+	}
+	if body.DefaultTTLSeconds > model.MaxTTLSeconds {
+		response.Validation(c, defaultTTLMessage)
 		return
 	}
 
-	if err := h.repo.AddSegment(c.Request.Context(), body.Slug, body.Percentage); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	segment := &model.SegmentDB{
+		Slug:              h.slugs.Normalize(body.Slug),
+		Description:       body.Description,
+		OwnerTeam:         body.OwnerTeam,
+		Percentage:        body.Percentage,
+		DefaultTTLSeconds: body.DefaultTTLSeconds,
+	}
+	if err := h.repo.AddSegment(c.Request.Context(), segment); err != nil {
+		if database.IsAddSegmentAlreadyExistsErr(err) {
+			response.Error(c, database.NewError(database.CodeAlreadyExists, fmt.Sprintf("segment %s already exists", segment.Slug)))
+			return
+		}
+
+		response.Error(c, err)
 		return
 	}
 
@@ -46,46 +83,308 @@ func (h *Handler) addSegment(c *gin.Context) {
 
 // @Summary Delete Segment
 // @Tags segment
-// @Description Delete segment with specified slug
+// @Description Archive segment with specified slug, its memberships end but stay in history
 // @Accept json
 // @Produce json
 // @Param body body DeleteSegmentRequest true "segment slug"
 // @Success 204
-// @Failure 400
-// @Failure 404
-// @Failure 500
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /segment/delete [delete]
 func (h *Handler) deleteSegment(c *gin.Context) {
 	var body DeleteSegmentRequest
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Validation(c, err.Error())
 		return
 	}
+	recorder.Segments(c, body.Slug)
 
 	if err := h.repo.DeleteSegment(c.Request.Context(), body.Slug); err != nil {
 		if database.IsRecordNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("segment %s not found", body.Slug)})
+			response.NotFound(c, fmt.Sprintf("segment %s not found", body.Slug))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Get Segments
+// @Tags segment
+// @Description Get segments with optional slug prefix filter
+// @Accept json
+// @Produce json
+// @Param prefix query string false "slug prefix"
+// @Param archived query bool false "include archived segments"
+// @Param limit query int false "page size" default(50)
+// @Param offset query int false "page offset"
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segment [get]
+func (h *Handler) getSegments(c *gin.Context) {
+	var query GetSegmentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	filter := &model.SegmentsFilter{
+		Prefix:   query.Prefix,
+		Archived: query.Archived,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	}
+	segments, err := h.repo.GetSegments(c.Request.Context(), filter)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	if segments == nil {
+		segments = []*model.Segment{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segments": segments, "limit": query.Limit, "offset": query.Offset})
+}
+
+// @Summary Get Segment
+// @Tags segment
+// @Description Get segment with specified slug
+// @Accept json
+// @Produce json
+// @Param slug path string true "segment slug"
+// @Success 200 {object} model.Segment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segment/{slug} [get]
+func (h *Handler) getSegment(c *gin.Context) {
+	var uri GetSegmentUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	segment, err := h.repo.GetSegment(c.Request.Context(), uri.Slug)
+	if err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("segment %s not found", uri.Slug))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// @Summary Get Segment Users
+// @Tags segment
+// @Description Get segment members ordered by user id, pass next_cursor from the response to get the next page
+// @Accept json
+// @Produce json
+// @Param slug path string true "segment slug"
+// @Param status query string false "members status" Enums(active, expired, scheduled, all) default(active)
+// @Param cursor query int false "last user ID of the previous page"
+// @Param limit query int false "page size" default(100)
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segment/{slug}/users [get]
+func (h *Handler) getSegmentUsers(c *gin.Context) {
+	var uri GetSegmentUsersUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	var query GetSegmentUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	filter := &model.MembersFilter{
+		Status: query.Status,
+		After:  query.Cursor,
+		Limit:  query.Limit,
+	}
+	members, err := h.repo.GetSegmentUsers(c.Request.Context(), uri.Slug, filter)
+	if err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("segment %s not found", uri.Slug))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+	if members == nil {
+		members = []*model.Member{}
+	}
+
+	var nextCursor *uint
+	if len(members) == query.Limit {
+		nextCursor = &members[len(members)-1].UserID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slug": uri.Slug, "users": members, "next_cursor": nextCursor})
+}
+
+// @Summary Update Segment
+// @Tags segment
+// @Description Update segment info, changing percentage ramps auto assigned members up or down
+// @Accept json
+// @Produce json
+// @Param slug path string true "segment slug"
+// @Param body body UpdateSegmentRequest true "segment fields to update"
+// @Success 204
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segment/{slug} [patch]
+func (h *Handler) updateSegment(c *gin.Context) {
+	var uri UpdateSegmentUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	recorder.Segments(c, uri.Slug)
+
+	var body UpdateSegmentRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	if body.Description == nil && body.OwnerTeam == nil && body.Percentage == nil && body.DefaultTTLSeconds == nil {
+		response.Validation(c, "nothing to update")
+		return
+	}
+
+	if body.Percentage != nil && *body.Percentage > 100 {
+		response.Validation(c, "invalid percentage")
+		return
+	}
+	if body.DefaultTTLSeconds != nil && *body.DefaultTTLSeconds > model.MaxTTLSeconds {
+		response.Validation(c, defaultTTLMessage)
+		return
+	}
+
+	update := &model.SegmentUpdate{
+		Description:       body.Description,
+		OwnerTeam:         body.OwnerTeam,
+		Percentage:        body.Percentage,
+		DefaultTTLSeconds: body.DefaultTTLSeconds,
+	}
+	if err := h.repo.UpdateSegment(c.Request.Context(), uri.Slug, update); err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("segment %s not found", uri.Slug))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Purge Segment
+// @Tags segment
+// @Description Permanently delete archived segment with its memberships, history keeps the slug,
+// @Description requires purge token on top of admin role
+// @Accept json
+// @Produce json
+// @Param X-Purge-Token header string true "purge token"
+// @Param body body PurgeSegmentRequest true "segment slug"
+// @Success 204
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segment/purge [delete]
+func (h *Handler) purgeSegment(c *gin.Context) {
+	token := c.GetHeader(PurgeTokenHeader)
+	if h.purgeToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.purgeToken)) != 1 {
+		response.Error(c, database.NewError(database.CodeForbidden, "purge is not authorized"))
+		return
+	}
+
+	var body PurgeSegmentRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	recorder.Segments(c, body.Slug)
+
+	if err := h.repo.PurgeSegment(c.Request.Context(), body.Slug); err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("segment %s not found", body.Slug))
+			return
+		}
+		if database.IsPurgeSegmentNotArchivedErr(err) {
+			response.Error(c, database.NewError(database.CodeConflict, fmt.Sprintf("segment %s must be archived before purge", body.Slug)))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.Error(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-func NewHandler(repo repo.Repo) *Handler {
+// NewHandler creates segment handler, new slugs are normalized by slugs policy,
+// purge is disabled when purgeToken is empty
+func NewHandler(repo repo.Repo, slugs *slug.Policy, purgeToken string) *Handler {
 	return &Handler{
-		repo: repo,
+		repo:       repo,
+		slugs:      slugs,
+		purgeToken: purgeToken,
 	}
 }
 
-func Route(r *gin.Engine, h *Handler) {
+func Route(r *gin.Engine, h *Handler, guard auth.Guard, audit recorder.Recorder) {
 	router := r.Group("segment")
 
 	{
-		router.POST("add", h.addSegment)
-		router.DELETE("delete", h.deleteSegment)
+		router.GET("", guard.Require(auth.RoleReader), h.getSegments)
+		router.GET("/:slug", guard.Require(auth.RoleReader), h.getSegment)
+		router.PATCH("/:slug", audit.Record(aModel.ActionSegmentUpdate), guard.Require(auth.RoleAdmin), h.updateSegment)
+		router.GET("/:slug/users", guard.Require(auth.RoleReader), h.getSegmentUsers)
+		router.POST("add", audit.Record(aModel.ActionSegmentAdd), guard.Require(auth.RoleAdmin), h.addSegment)
+		router.DELETE("delete", audit.Record(aModel.ActionSegmentArchive), guard.Require(auth.RoleAdmin), h.deleteSegment)
+		router.DELETE("purge", audit.Record(aModel.ActionSegmentPurge), guard.Require(auth.RoleAdmin), h.purgeSegment)
 	}
 }
@@ -8,32 +8,61 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
+	"avito_2023/internal/audit/recorder"
+	"avito_2023/internal/auth"
 	"avito_2023/internal/database"
 	"avito_2023/internal/segment/handler"
+	"avito_2023/internal/segment/model"
 	"avito_2023/internal/segment/repo/mocks"
+	"avito_2023/internal/segment/slug"
+)
+
+const (
+	testAdminKey   = "test-admin-key"
+	testReaderKey  = "test-reader-key"
+	testPurgeToken = "test-purge-token"
 )
 
 type Suite struct {
 	suite.Suite
 
 	r       *gin.Engine
+	authR   *gin.Engine
 	repo    *mocks.RepoMock
 	handler *handler.Handler
 }
 
 func (s *Suite) SetupSuite() {
 	s.repo = &mocks.RepoMock{}
-	s.handler = handler.NewHandler(s.repo)
+
+	slugs, err := slug.NewPolicy(slug.DefaultOptions())
+	s.Require().NoError(err)
+	s.Require().NoError(slugs.RegisterValidation())
+	s.handler = handler.NewHandler(s.repo, slugs, testPurgeToken)
 
 	gin.SetMode(gin.TestMode)
 	s.r = gin.Default()
 
-	handler.Route(s.r, s.handler)
+	handler.Route(s.r, s.handler, auth.Disabled{}, recorder.Disabled{})
+
+	clients, err := auth.ParseClients([]byte(fmt.Sprintf(`
+clients:
+  - name: admin
+    role: admin
+    api_key_sha256: %s
+  - name: reader
+    role: reader
+    api_key_sha256: %s
+`, auth.HashAPIKey(testAdminKey), auth.HashAPIKey(testReaderKey))), "")
+	s.Require().NoError(err)
+	s.authR = gin.Default()
+	handler.Route(s.authR, s.handler, auth.NewAuthenticator(clients), recorder.Disabled{})
 }
 
 func TestSuite(t *testing.T) {
@@ -44,7 +73,7 @@ func (s *Suite) TestAddSegment() {
 	testCases := []struct {
 		name         string
 		inputBody    map[string]interface{}
-		mockFc       func(ctx context.Context, slug string, percentage uint) error
+		mockFc       func(ctx context.Context, segment *model.SegmentDB) error
 		expectedCode int
 		expectedErr  string
 	}{
@@ -53,7 +82,7 @@ func (s *Suite) TestAddSegment() {
 			inputBody: map[string]interface{}{
 				"slug": "test-slug",
 			},
-			mockFc: func(ctx context.Context, slug string, percentage uint) error {
+			mockFc: func(ctx context.Context, segment *model.SegmentDB) error {
 				return nil
 			},
 			expectedCode: http.StatusCreated,
@@ -64,7 +93,36 @@ func (s *Suite) TestAddSegment() {
 				"slug":       "test-slug",
 				"percentage": 10,
 			},
-			mockFc: func(ctx context.Context, slug string, percentage uint) error {
+			mockFc: func(ctx context.Context, segment *model.SegmentDB) error {
+				return nil
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "add segment with default ttl",
+			inputBody: map[string]interface{}{
+				"slug":                "test-slug",
+				"default_ttl_seconds": 172800,
+			},
+			mockFc: func(ctx context.Context, segment *model.SegmentDB) error {
+				if segment.DefaultTTLSeconds != 172800 {
+					return fmt.Errorf("unexpected default ttl")
+				}
+				return nil
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "add segment with metadata",
+			inputBody: map[string]interface{}{
+				"slug":        "test-slug",
+				"description": "test segment",
+				"owner_team":  "test-team",
+			},
+			mockFc: func(ctx context.Context, segment *model.SegmentDB) error {
+				if segment.Description != "test segment" || segment.OwnerTeam != "test-team" {
+					return fmt.Errorf("unexpected segment metadata")
+				}
 				return nil
 			},
 			expectedCode: http.StatusCreated,
@@ -76,6 +134,22 @@ func (s *Suite) TestAddSegment() {
 			},
 			expectedCode: http.StatusBadRequest,
 		},
+		{
+			name: "invalid request body (slug charset)",
+			inputBody: map[string]interface{}{
+				"slug": "AVITO VOICE MESSAGES",
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "validation_failed",
+		},
+		{
+			name: "invalid request body (slug length)",
+			inputBody: map[string]interface{}{
+				"slug": "AVITO_VOICE_MESSAGES_AVITO_VOICE_MESSAGES_AVITO_VOICE",
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "validation_failed",
+		},
 		{
 			name: "invalid request body (percentage)",
 			inputBody: map[string]interface{}{
@@ -84,16 +158,36 @@ func (s *Suite) TestAddSegment() {
 			},
 			expectedCode: http.StatusBadRequest,
 		},
+		{
+			name: "invalid request body (default ttl overflow)",
+			inputBody: map[string]interface{}{
+				"slug":                "test-slug",
+				"default_ttl_seconds": uint64(10000000000),
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  `{"error":{"code":"validation_failed","message":"default_ttl_seconds must be at most 315360000"}}`,
+		},
+		{
+			name: "segment already exists",
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			mockFc: func(ctx context.Context, segment *model.SegmentDB) error {
+				return database.ErrAddSegment_AlreadyExists
+			},
+			expectedCode: http.StatusConflict,
+			expectedErr:  `{"error":{"code":"already_exists","message":"segment test-slug already exists"}}`,
+		},
 		{
 			name: "failed to add segment to db",
 			inputBody: map[string]interface{}{
 				"slug": "test-slug",
 			},
-			mockFc: func(ctx context.Context, slug string, percentage uint) error {
+			mockFc: func(ctx context.Context, segment *model.SegmentDB) error {
 				return fmt.Errorf("something went wrong")
 			},
 			expectedCode: http.StatusInternalServerError,
-			expectedErr:  "something went wrong",
+			expectedErr:  "internal error",
 		},
 	}
 
@@ -162,7 +256,7 @@ func (s *Suite) TestDeleteSegment() {
 				return fmt.Errorf("something went wrong")
 			},
 			expectedCode: http.StatusInternalServerError,
-			expectedErr:  "something went wrong",
+			expectedErr:  "internal error",
 		},
 	}
 
@@ -185,3 +279,563 @@ func (s *Suite) TestDeleteSegment() {
 		})
 	}
 }
+
+func (s *Suite) TestGetSegments() {
+	createdAt := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		inputQuery   string
+		mockFc       func(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:       "get segments",
+			inputQuery: "prefix=AVITO_DISCOUNT&limit=2&offset=2",
+			mockFc: func(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error) {
+				if filter.Prefix != "AVITO_DISCOUNT" || filter.Archived || filter.Limit != 2 || filter.Offset != 2 {
+					return nil, fmt.Errorf("unexpected query")
+				}
+				return []*model.Segment{
+					{ID: 3, Slug: "AVITO_DISCOUNT_30", CreatedAt: createdAt, UpdatedAt: createdAt, MembersCount: 10},
+					{ID: 4, Slug: "AVITO_DISCOUNT_50", Percentage: 5, CreatedAt: createdAt, UpdatedAt: createdAt, MembersCount: 0},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "limit": 2,
+				  "offset": 2,
+				  "segments": [
+					{"id": 3, "slug": "AVITO_DISCOUNT_30", "description": "", "owner_team": "", "percentage": 0, "default_ttl_seconds": 0, "created_at": "2023-08-15T12:30:00Z", "updated_at": "2023-08-15T12:30:00Z", "archived_at": null, "members_count": 10},
+					{"id": 4, "slug": "AVITO_DISCOUNT_50", "description": "", "owner_team": "", "percentage": 5, "default_ttl_seconds": 0, "created_at": "2023-08-15T12:30:00Z", "updated_at": "2023-08-15T12:30:00Z", "archived_at": null, "members_count": 0}
+				  ]
+				}
+			`,
+		},
+		{
+			name: "no segments",
+			mockFc: func(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error) {
+				return nil, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `{"limit": 50, "offset": 0, "segments": []}`,
+		},
+		{
+			name:         "invalid request query (limit)",
+			inputQuery:   "limit=0",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "failed to get segments from db",
+			mockFc: func(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedResp: `{"error": {"code": "internal_error", "message": "internal error"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.GetSegmentsFunc = tc.mockFc
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/segment?"+tc.inputQuery, nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestGetSegment() {
+	createdAt := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		inputSlug    string
+		mockFc       func(ctx context.Context, slug string) (*model.Segment, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:      "get segment",
+			inputSlug: "AVITO_VOICE_MESSAGES",
+			mockFc: func(ctx context.Context, slug string) (*model.Segment, error) {
+				return &model.Segment{
+					ID:                1,
+					Slug:              slug,
+					Description:       "voice messages in chats",
+					OwnerTeam:         "messenger",
+					Percentage:        10,
+					DefaultTTLSeconds: 172800,
+					CreatedAt:         createdAt,
+					UpdatedAt:         createdAt.Add(time.Hour),
+					MembersCount:      42,
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "id": 1,
+				  "slug": "AVITO_VOICE_MESSAGES",
+				  "description": "voice messages in chats",
+				  "owner_team": "messenger",
+				  "percentage": 10,
+				  "default_ttl_seconds": 172800,
+				  "created_at": "2023-08-15T12:30:00Z",
+				  "updated_at": "2023-08-15T13:30:00Z",
+				  "archived_at": null,
+				  "members_count": 42
+				}
+			`,
+		},
+		{
+			name:      "get segment created before slug policy",
+			inputSlug: "avito.legacy",
+			mockFc: func(ctx context.Context, slug string) (*model.Segment, error) {
+				return &model.Segment{
+					ID:        2,
+					Slug:      slug,
+					CreatedAt: createdAt,
+					UpdatedAt: createdAt,
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "id": 2,
+				  "slug": "avito.legacy",
+				  "description": "",
+				  "owner_team": "",
+				  "percentage": 0,
+				  "default_ttl_seconds": 0,
+				  "created_at": "2023-08-15T12:30:00Z",
+				  "updated_at": "2023-08-15T12:30:00Z",
+				  "archived_at": null,
+				  "members_count": 0
+				}
+			`,
+		},
+		{
+			name:      "segment not found",
+			inputSlug: "AVITO_VOICE_MESSAGES",
+			mockFc: func(ctx context.Context, slug string) (*model.Segment, error) {
+				return nil, database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedResp: `{"error": {"code": "not_found", "message": "segment AVITO_VOICE_MESSAGES not found"}}`,
+		},
+		{
+			name:      "failed to get segment from db",
+			inputSlug: "AVITO_VOICE_MESSAGES",
+			mockFc: func(ctx context.Context, slug string) (*model.Segment, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedResp: `{"error": {"code": "internal_error", "message": "internal error"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.GetSegmentFunc = tc.mockFc
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/segment/"+tc.inputSlug, nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestUpdateSegment() {
+	testCases := []struct {
+		name         string
+		inputSlug    string
+		inputBody    map[string]interface{}
+		mockFc       func(ctx context.Context, slug string, update *model.SegmentUpdate) error
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:      "ramp up percentage",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"percentage": 50,
+			},
+			mockFc: func(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+				if update.Percentage == nil || *update.Percentage != 50 || update.Description != nil {
+					return fmt.Errorf("unexpected update")
+				}
+				return nil
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:      "update default ttl",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"default_ttl_seconds": 172800,
+			},
+			mockFc: func(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+				if update.DefaultTTLSeconds == nil || *update.DefaultTTLSeconds != 172800 || update.Percentage != nil {
+					return fmt.Errorf("unexpected update")
+				}
+				return nil
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:      "turn off percentage and update description",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"percentage":  0,
+				"description": "test segment",
+			},
+			mockFc: func(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+				if update.Percentage == nil || *update.Percentage != 0 || *update.Description != "test segment" {
+					return fmt.Errorf("unexpected update")
+				}
+				return nil
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "nothing to update",
+			inputSlug:    "test-slug",
+			inputBody:    map[string]interface{}{},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "nothing to update",
+		},
+		{
+			name:      "invalid request body (default ttl overflow)",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"default_ttl_seconds": uint64(10000000000),
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  `{"error":{"code":"validation_failed","message":"default_ttl_seconds must be at most 315360000"}}`,
+		},
+		{
+			name:      "invalid request body (percentage)",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"percentage": 101,
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "invalid percentage",
+		},
+		{
+			name:      "segment not found",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"percentage": 50,
+			},
+			mockFc: func(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+				return database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "segment test-slug not found",
+		},
+		{
+			name:      "failed to update segment in db",
+			inputSlug: "test-slug",
+			inputBody: map[string]interface{}{
+				"percentage": 50,
+			},
+			mockFc: func(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+				return fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.UpdateSegmentFunc = tc.mockFc
+			}
+
+			b, _ := json.Marshal(tc.inputBody)
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/segment/"+tc.inputSlug, bytes.NewBuffer(b))
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedErr != "" {
+				assert.Contains(t, res.Body.String(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *Suite) TestGetSegmentUsers() {
+	joinedAt := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+	deleteAt := joinedAt.AddDate(0, 0, 2)
+
+	testCases := []struct {
+		name         string
+		inputQuery   string
+		mockFc       func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:       "get segment users",
+			inputQuery: "limit=2",
+			mockFc: func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+				if filter.Status != model.MemberStatusActive || filter.After != 0 || filter.Limit != 2 {
+					return nil, fmt.Errorf("unexpected filter")
+				}
+				return []*model.Member{
+					{UserID: 1000, Source: "manual", Active: true, JoinedAt: joinedAt},
+					{UserID: 1002, Source: "percentage", Active: true, JoinedAt: joinedAt, DeleteAt: &deleteAt},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "slug": "test-slug",
+				  "users": [
+					{"user_id": 1000, "source": "manual", "active": true, "joined_at": "2023-08-15T12:30:00Z", "delete_at": null},
+					{"user_id": 1002, "source": "percentage", "active": true, "joined_at": "2023-08-15T12:30:00Z", "delete_at": "2023-08-17T12:30:00Z"}
+				  ],
+				  "next_cursor": 1002
+				}
+			`,
+		},
+		{
+			name:       "get last page of expired users",
+			inputQuery: "status=expired&cursor=1002&limit=2",
+			mockFc: func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+				if filter.Status != model.MemberStatusExpired || filter.After != 1002 {
+					return nil, fmt.Errorf("unexpected filter")
+				}
+				return []*model.Member{
+					{UserID: 1004, Source: "manual", JoinedAt: joinedAt, DeleteAt: &deleteAt},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "slug": "test-slug",
+				  "users": [
+					{"user_id": 1004, "source": "manual", "active": false, "joined_at": "2023-08-15T12:30:00Z", "delete_at": "2023-08-17T12:30:00Z"}
+				  ],
+				  "next_cursor": null
+				}
+			`,
+		},
+		{
+			name:       "no users",
+			inputQuery: "status=all",
+			mockFc: func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+				return nil, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `{"slug": "test-slug", "users": [], "next_cursor": null}`,
+		},
+		{
+			name:         "invalid request query (status)",
+			inputQuery:   "status=wrong",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "segment not found",
+			mockFc: func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+				return nil, database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedResp: `{"error": {"code": "not_found", "message": "segment test-slug not found"}}`,
+		},
+		{
+			name: "failed to get segment users from db",
+			mockFc: func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedResp: `{"error": {"code": "internal_error", "message": "internal error"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.GetSegmentUsersFunc = tc.mockFc
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/segment/test-slug/users?"+tc.inputQuery, nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestPurgeSegment() {
+	testCases := []struct {
+		name         string
+		inputAPIKey  string
+		inputToken   string
+		inputBody    map[string]interface{}
+		mockFc       func(ctx context.Context, slug string) error
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:        "purge segment",
+			inputAPIKey: testAdminKey,
+			inputToken:  testPurgeToken,
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			mockFc: func(ctx context.Context, slug string) error {
+				return nil
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "missing credentials",
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedErr:  `{"error":{"code":"unauthorized","message":"missing credentials"}}`,
+		},
+		{
+			name:        "wrong api key",
+			inputAPIKey: "wrong",
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedErr:  `{"error":{"code":"unauthorized","message":"invalid credentials"}}`,
+		},
+		{
+			name:        "not admin",
+			inputAPIKey: testReaderKey,
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  `{"error":{"code":"forbidden","message":"role admin is required"}}`,
+		},
+		{
+			name:        "missing purge token",
+			inputAPIKey: testAdminKey,
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  `{"error":{"code":"forbidden","message":"purge is not authorized"}}`,
+		},
+		{
+			name:        "wrong purge token",
+			inputAPIKey: testAdminKey,
+			inputToken:  "wrong",
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  `{"error":{"code":"forbidden","message":"purge is not authorized"}}`,
+		},
+		{
+			name:        "invalid request body",
+			inputAPIKey: testAdminKey,
+			inputToken:  testPurgeToken,
+			inputBody: map[string]interface{}{
+				"wrong": "wrong",
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:        "segment not archived",
+			inputAPIKey: testAdminKey,
+			inputToken:  testPurgeToken,
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			mockFc: func(ctx context.Context, slug string) error {
+				return database.ErrPurgeSegment_NotArchived
+			},
+			expectedCode: http.StatusConflict,
+			expectedErr:  "segment test-slug must be archived before purge",
+		},
+		{
+			name:        "segment not found",
+			inputAPIKey: testAdminKey,
+			inputToken:  testPurgeToken,
+			inputBody: map[string]interface{}{
+				"slug": "test-slug",
+			},
+			mockFc: func(ctx context.Context, slug string) error {
+				return database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "segment test-slug not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.PurgeSegmentFunc = tc.mockFc
+			}
+
+			b, _ := json.Marshal(tc.inputBody)
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/segment/purge", bytes.NewBuffer(b))
+			if tc.inputAPIKey != "" {
+				req.Header.Set(auth.APIKeyHeader, tc.inputAPIKey)
+			}
+			if tc.inputToken != "" {
+				req.Header.Set(handler.PurgeTokenHeader, tc.inputToken)
+			}
+			s.authR.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedErr != "" {
+				assert.Contains(t, res.Body.String(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *Suite) TestPurgeSegmentDisabled() {
+	slugs, err := slug.NewPolicy(slug.DefaultOptions())
+	s.Require().NoError(err)
+	r := gin.New()
+	handler.Route(r, handler.NewHandler(s.repo, slugs, ""), auth.Disabled{}, recorder.Disabled{})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/segment/purge", bytes.NewBufferString(`{"slug": "test-slug"}`))
+	req.Header.Set(handler.PurgeTokenHeader, "")
+	r.ServeHTTP(res, req)
+
+	s.Equal(http.StatusForbidden, res.Code)
+	s.Equal(`{"error":{"code":"forbidden","message":"purge is not authorized"}}`, res.Body.String())
+}
@@ -1,10 +1,52 @@
 package handler
 
 type AddSegmentRequest struct {
-	Slug       string `json:"slug" binding:"required"`
-	Percentage uint   `json:"percentage"`
+	Slug        string `json:"slug" binding:"required,slug"`
+	Description string `json:"description"`
+	OwnerTeam   string `json:"owner_team" binding:"max=100"`
+	Percentage  uint   `json:"percentage"`
+	// DefaultTTLSeconds is applied to memberships added without explicit TTL, 0 means unlimited,
+	// at most model.MaxTTLSeconds
+	DefaultTTLSeconds uint `json:"default_ttl_seconds"`
 }
 
 type DeleteSegmentRequest struct {
-	Slug string `json:"slug" binding:"required"`
+	Slug string `json:"slug" binding:"required,max=50"`
+}
+
+type PurgeSegmentRequest struct {
+	Slug string `json:"slug" binding:"required,max=50"`
+}
+
+type GetSegmentsQuery struct {
+	Prefix   string `form:"prefix"`
+	Archived bool   `form:"archived"`
+	Limit    int    `form:"limit,default=50" binding:"min=1,max=1000"`
+	Offset   int    `form:"offset" binding:"min=0"`
+}
+
+type GetSegmentUri struct {
+	Slug string `uri:"slug" binding:"required,max=50"`
+}
+
+type UpdateSegmentUri struct {
+	Slug string `uri:"slug" binding:"required,max=50"`
+}
+
+type UpdateSegmentRequest struct {
+	Description *string `json:"description"`
+	OwnerTeam   *string `json:"owner_team" binding:"omitempty,max=100"`
+	Percentage  *uint   `json:"percentage"`
+	// DefaultTTLSeconds 0 removes the default TTL
+	DefaultTTLSeconds *uint `json:"default_ttl_seconds"`
+}
+
+type GetSegmentUsersUri struct {
+	Slug string `uri:"slug" binding:"required,max=50"`
+}
+
+type GetSegmentUsersQuery struct {
+	Status string `form:"status,default=active" binding:"oneof=active expired scheduled all"`
+	Cursor uint   `form:"cursor"`
+	Limit  int    `form:"limit,default=100" binding:"min=1,max=1000"`
 }
@@ -1,10 +1,95 @@
 package model
 
+import (
+	"time"
+)
+
 type SegmentDB struct {
-	ID   uint   `gorm:"id"`
-	Slug string `gorm:"slug"`
+	ID          uint   `gorm:"id"`
+	Slug        string `gorm:"slug"`
+	Description string `gorm:"description"`
+	OwnerTeam   string `gorm:"owner_team"`
+	Percentage  uint   `gorm:"percentage"`
+	Salt        string `gorm:"salt"`
+	// DefaultTTLSeconds limits memberships added without explicit TTL, 0 means unlimited
+	DefaultTTLSeconds uint       `gorm:"default_ttl_seconds"`
+	CreatedAt         time.Time  `gorm:"created_at"`
+	UpdatedAt         time.Time  `gorm:"updated_at"`
+	ArchivedAt        *time.Time `gorm:"archived_at"`
 }
 
 func (SegmentDB) TableName() string {
 	return "segments"
 }
+
+// MaxTTLSeconds bounds segments default TTL and memberships TTL, it is 10 years,
+// larger TTLs overflow time.Duration
+const MaxTTLSeconds = 10 * 365 * 24 * 60 * 60
+
+// DefaultDeleteAt returns the end of membership starting at from according to the segment default TTL
+func (s *SegmentDB) DefaultDeleteAt(from time.Time) *time.Time {
+	if s.DefaultTTLSeconds == 0 {
+		return nil
+	}
+
+	deleteAt := from.Add(time.Duration(s.DefaultTTLSeconds) * time.Second)
+	// TTL stored before it was bounded may overflow, such TTL is treated as unlimited
+	// rather than expiring memberships right away
+	if !deleteAt.After(from) {
+		return nil
+	}
+
+	return &deleteAt
+}
+
+type Segment struct {
+	ID                uint       `gorm:"id" json:"id"`
+	Slug              string     `gorm:"slug" json:"slug"`
+	Description       string     `gorm:"description" json:"description"`
+	OwnerTeam         string     `gorm:"owner_team" json:"owner_team"`
+	Percentage        uint       `gorm:"percentage" json:"percentage"`
+	DefaultTTLSeconds uint       `gorm:"default_ttl_seconds" json:"default_ttl_seconds"`
+	CreatedAt         time.Time  `gorm:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"updated_at" json:"updated_at"`
+	ArchivedAt        *time.Time `gorm:"archived_at" json:"archived_at"`
+	MembersCount      uint       `gorm:"members_count" json:"members_count"`
+}
+
+// SegmentsFilter selects a page of segments ordered by slug
+type SegmentsFilter struct {
+	Prefix   string
+	Archived bool
+	Limit    int
+	Offset   int
+}
+
+// SegmentUpdate holds segment fields to change, nil fields are kept
+type SegmentUpdate struct {
+	Description *string
+	OwnerTeam   *string
+	Percentage  *uint
+	// DefaultTTLSeconds 0 removes the default TTL
+	DefaultTTLSeconds *uint
+}
+
+const (
+	MemberStatusActive    = "active"
+	MemberStatusExpired   = "expired"
+	MemberStatusScheduled = "scheduled"
+	MemberStatusAll       = "all"
+)
+
+// MembersFilter selects a page of segment members ordered by user id
+type MembersFilter struct {
+	Status string
+	After  uint
+	Limit  int
+}
+
+type Member struct {
+	UserID   uint       `gorm:"user_id" json:"user_id"`
+	Source   string     `gorm:"source" json:"source"`
+	Active   bool       `gorm:"active" json:"active"`
+	JoinedAt time.Time  `gorm:"joined_at" json:"joined_at"`
+	DeleteAt *time.Time `gorm:"delete_at" json:"delete_at"`
+}
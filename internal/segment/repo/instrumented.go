@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"context"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/segment/model"
+)
+
+// instrumentedRepo attributes queries of every repo method to it and reports the method
+// to hooks registered with database.AddMethodHook
+type instrumentedRepo struct {
+	repo Repo
+}
+
+func (r *instrumentedRepo) AddSegment(ctx context.Context, segment *model.SegmentDB) (err error) {
+	ctx, end := database.StartMethod(ctx, "segment.AddSegment")
+	defer func() { end(err) }()
+
+	return r.repo.AddSegment(ctx, segment)
+}
+
+func (r *instrumentedRepo) DeleteSegment(ctx context.Context, slug string) (err error) {
+	ctx, end := database.StartMethod(ctx, "segment.DeleteSegment")
+	defer func() { end(err) }()
+
+	return r.repo.DeleteSegment(ctx, slug)
+}
+
+func (r *instrumentedRepo) PurgeSegment(ctx context.Context, slug string) (err error) {
+	ctx, end := database.StartMethod(ctx, "segment.PurgeSegment")
+	defer func() { end(err) }()
+
+	return r.repo.PurgeSegment(ctx, slug)
+}
+
+func (r *instrumentedRepo) GetSegments(ctx context.Context, filter *model.SegmentsFilter) (result []*model.Segment, err error) {
+	ctx, end := database.StartMethod(ctx, "segment.GetSegments")
+	defer func() { end(err) }()
+
+	return r.repo.GetSegments(ctx, filter)
+}
+
+func (r *instrumentedRepo) GetSegment(ctx context.Context, slug string) (result *model.Segment, err error) {
+	ctx, end := database.StartMethod(ctx, "segment.GetSegment")
+	defer func() { end(err) }()
+
+	return r.repo.GetSegment(ctx, slug)
+}
+
+func (r *instrumentedRepo) GetSegmentUsers(ctx context.Context, slug string, filter *model.MembersFilter) (result []*model.Member, err error) {
+	ctx, end := database.StartMethod(ctx, "segment.GetSegmentUsers")
+	defer func() { end(err) }()
+
+	return r.repo.GetSegmentUsers(ctx, slug, filter)
+}
+
+func (r *instrumentedRepo) UpdateSegment(ctx context.Context, slug string, update *model.SegmentUpdate) (err error) {
+	ctx, end := database.StartMethod(ctx, "segment.UpdateSegment")
+	defer func() { end(err) }()
+
+	return r.repo.UpdateSegment(ctx, slug, update)
+}
+
+func (r *instrumentedRepo) CountActiveMembers(ctx context.Context) (result map[string]uint, err error) {
+	ctx, end := database.StartMethod(ctx, "segment.CountActiveMembers")
+	defer func() { end(err) }()
+
+	return r.repo.CountActiveMembers(ctx)
+}
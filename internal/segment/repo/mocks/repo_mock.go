@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	"avito_2023/internal/segment/model"
 	"avito_2023/internal/segment/repo"
 	"context"
 	"sync"
@@ -19,12 +20,30 @@ var _ repo.Repo = &RepoMock{}
 //
 //		// make and configure a mocked repo.Repo
 //		mockedRepo := &RepoMock{
-//			AddSegmentFunc: func(ctx context.Context, slug string, percentage uint) error {
+//			AddSegmentFunc: func(ctx context.Context, segment *model.SegmentDB) error {
 //				panic("mock out the AddSegment method")
 //			},
+//			CountActiveMembersFunc: func(ctx context.Context) (map[string]uint, error) {
+//				panic("mock out the CountActiveMembers method")
+//			},
 //			DeleteSegmentFunc: func(ctx context.Context, slug string) error {
 //				panic("mock out the DeleteSegment method")
 //			},
+//			GetSegmentFunc: func(ctx context.Context, slug string) (*model.Segment, error) {
+//				panic("mock out the GetSegment method")
+//			},
+//			GetSegmentUsersFunc: func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+//				panic("mock out the GetSegmentUsers method")
+//			},
+//			GetSegmentsFunc: func(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error) {
+//				panic("mock out the GetSegments method")
+//			},
+//			PurgeSegmentFunc: func(ctx context.Context, slug string) error {
+//				panic("mock out the PurgeSegment method")
+//			},
+//			UpdateSegmentFunc: func(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+//				panic("mock out the UpdateSegment method")
+//			},
 //		}
 //
 //		// use mockedRepo in code that requires repo.Repo
@@ -33,21 +52,42 @@ var _ repo.Repo = &RepoMock{}
 //	}
 type RepoMock struct {
 	// AddSegmentFunc mocks the AddSegment method.
-	AddSegmentFunc func(ctx context.Context, slug string, percentage uint) error
+	AddSegmentFunc func(ctx context.Context, segment *model.SegmentDB) error
+
+	// CountActiveMembersFunc mocks the CountActiveMembers method.
+	CountActiveMembersFunc func(ctx context.Context) (map[string]uint, error)
 
 	// DeleteSegmentFunc mocks the DeleteSegment method.
 	DeleteSegmentFunc func(ctx context.Context, slug string) error
 
+	// GetSegmentFunc mocks the GetSegment method.
+	GetSegmentFunc func(ctx context.Context, slug string) (*model.Segment, error)
+
+	// GetSegmentUsersFunc mocks the GetSegmentUsers method.
+	GetSegmentUsersFunc func(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error)
+
+	// GetSegmentsFunc mocks the GetSegments method.
+	GetSegmentsFunc func(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error)
+
+	// PurgeSegmentFunc mocks the PurgeSegment method.
+	PurgeSegmentFunc func(ctx context.Context, slug string) error
+
+	// UpdateSegmentFunc mocks the UpdateSegment method.
+	UpdateSegmentFunc func(ctx context.Context, slug string, update *model.SegmentUpdate) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// AddSegment holds details about calls to the AddSegment method.
 		AddSegment []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// Slug is the slug argument value.
-			Slug string
-			// Percentage is the percentage argument value.
-			Percentage uint
+			// Segment is the segment argument value.
+			Segment *model.SegmentDB
+		}
+		// CountActiveMembers holds details about calls to the CountActiveMembers method.
+		CountActiveMembers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 		}
 		// DeleteSegment holds details about calls to the DeleteSegment method.
 		DeleteSegment []struct {
@@ -56,29 +96,72 @@ type RepoMock struct {
 			// Slug is the slug argument value.
 			Slug string
 		}
+		// GetSegment holds details about calls to the GetSegment method.
+		GetSegment []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Slug is the slug argument value.
+			Slug string
+		}
+		// GetSegmentUsers holds details about calls to the GetSegmentUsers method.
+		GetSegmentUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Slug is the slug argument value.
+			Slug string
+			// Filter is the filter argument value.
+			Filter *model.MembersFilter
+		}
+		// GetSegments holds details about calls to the GetSegments method.
+		GetSegments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter *model.SegmentsFilter
+		}
+		// PurgeSegment holds details about calls to the PurgeSegment method.
+		PurgeSegment []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Slug is the slug argument value.
+			Slug string
+		}
+		// UpdateSegment holds details about calls to the UpdateSegment method.
+		UpdateSegment []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Slug is the slug argument value.
+			Slug string
+			// Update is the update argument value.
+			Update *model.SegmentUpdate
+		}
 	}
-	lockAddSegment    sync.RWMutex
-	lockDeleteSegment sync.RWMutex
+	lockAddSegment         sync.RWMutex
+	lockCountActiveMembers sync.RWMutex
+	lockDeleteSegment      sync.RWMutex
+	lockGetSegment         sync.RWMutex
+	lockGetSegmentUsers    sync.RWMutex
+	lockGetSegments        sync.RWMutex
+	lockPurgeSegment       sync.RWMutex
+	lockUpdateSegment      sync.RWMutex
 }
 
 // AddSegment calls AddSegmentFunc.
-func (mock *RepoMock) AddSegment(ctx context.Context, slug string, percentage uint) error {
+func (mock *RepoMock) AddSegment(ctx context.Context, segment *model.SegmentDB) error {
 	if mock.AddSegmentFunc == nil {
 		panic("RepoMock.AddSegmentFunc: method is nil but Repo.AddSegment was just called")
 	}
 	callInfo := struct {
-		Ctx        context.Context
-		Slug       string
-		Percentage uint
+		Ctx     context.Context
+		Segment *model.SegmentDB
 	}{
-		Ctx:        ctx,
-		Slug:       slug,
-		Percentage: percentage,
+		Ctx:     ctx,
+		Segment: segment,
 	}
 	mock.lockAddSegment.Lock()
 	mock.calls.AddSegment = append(mock.calls.AddSegment, callInfo)
 	mock.lockAddSegment.Unlock()
-	return mock.AddSegmentFunc(ctx, slug, percentage)
+	return mock.AddSegmentFunc(ctx, segment)
 }
 
 // AddSegmentCalls gets all the calls that were made to AddSegment.
@@ -86,14 +169,12 @@ func (mock *RepoMock) AddSegment(ctx context.Context, slug string, percentage ui
 //
 //	len(mockedRepo.AddSegmentCalls())
 func (mock *RepoMock) AddSegmentCalls() []struct {
-	Ctx        context.Context
-	Slug       string
-	Percentage uint
+	Ctx     context.Context
+	Segment *model.SegmentDB
 } {
 	var calls []struct {
-		Ctx        context.Context
-		Slug       string
-		Percentage uint
+		Ctx     context.Context
+		Segment *model.SegmentDB
 	}
 	mock.lockAddSegment.RLock()
 	calls = mock.calls.AddSegment
@@ -101,6 +182,38 @@ func (mock *RepoMock) AddSegmentCalls() []struct {
 	return calls
 }
 
+// CountActiveMembers calls CountActiveMembersFunc.
+func (mock *RepoMock) CountActiveMembers(ctx context.Context) (map[string]uint, error) {
+	if mock.CountActiveMembersFunc == nil {
+		panic("RepoMock.CountActiveMembersFunc: method is nil but Repo.CountActiveMembers was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockCountActiveMembers.Lock()
+	mock.calls.CountActiveMembers = append(mock.calls.CountActiveMembers, callInfo)
+	mock.lockCountActiveMembers.Unlock()
+	return mock.CountActiveMembersFunc(ctx)
+}
+
+// CountActiveMembersCalls gets all the calls that were made to CountActiveMembers.
+// Check the length with:
+//
+//	len(mockedRepo.CountActiveMembersCalls())
+func (mock *RepoMock) CountActiveMembersCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockCountActiveMembers.RLock()
+	calls = mock.calls.CountActiveMembers
+	mock.lockCountActiveMembers.RUnlock()
+	return calls
+}
+
 // DeleteSegment calls DeleteSegmentFunc.
 func (mock *RepoMock) DeleteSegment(ctx context.Context, slug string) error {
 	if mock.DeleteSegmentFunc == nil {
@@ -136,3 +249,191 @@ func (mock *RepoMock) DeleteSegmentCalls() []struct {
 	mock.lockDeleteSegment.RUnlock()
 	return calls
 }
+
+// GetSegment calls GetSegmentFunc.
+func (mock *RepoMock) GetSegment(ctx context.Context, slug string) (*model.Segment, error) {
+	if mock.GetSegmentFunc == nil {
+		panic("RepoMock.GetSegmentFunc: method is nil but Repo.GetSegment was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Slug string
+	}{
+		Ctx:  ctx,
+		Slug: slug,
+	}
+	mock.lockGetSegment.Lock()
+	mock.calls.GetSegment = append(mock.calls.GetSegment, callInfo)
+	mock.lockGetSegment.Unlock()
+	return mock.GetSegmentFunc(ctx, slug)
+}
+
+// GetSegmentCalls gets all the calls that were made to GetSegment.
+// Check the length with:
+//
+//	len(mockedRepo.GetSegmentCalls())
+func (mock *RepoMock) GetSegmentCalls() []struct {
+	Ctx  context.Context
+	Slug string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Slug string
+	}
+	mock.lockGetSegment.RLock()
+	calls = mock.calls.GetSegment
+	mock.lockGetSegment.RUnlock()
+	return calls
+}
+
+// GetSegmentUsers calls GetSegmentUsersFunc.
+func (mock *RepoMock) GetSegmentUsers(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+	if mock.GetSegmentUsersFunc == nil {
+		panic("RepoMock.GetSegmentUsersFunc: method is nil but Repo.GetSegmentUsers was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Slug   string
+		Filter *model.MembersFilter
+	}{
+		Ctx:    ctx,
+		Slug:   slug,
+		Filter: filter,
+	}
+	mock.lockGetSegmentUsers.Lock()
+	mock.calls.GetSegmentUsers = append(mock.calls.GetSegmentUsers, callInfo)
+	mock.lockGetSegmentUsers.Unlock()
+	return mock.GetSegmentUsersFunc(ctx, slug, filter)
+}
+
+// GetSegmentUsersCalls gets all the calls that were made to GetSegmentUsers.
+// Check the length with:
+//
+//	len(mockedRepo.GetSegmentUsersCalls())
+func (mock *RepoMock) GetSegmentUsersCalls() []struct {
+	Ctx    context.Context
+	Slug   string
+	Filter *model.MembersFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Slug   string
+		Filter *model.MembersFilter
+	}
+	mock.lockGetSegmentUsers.RLock()
+	calls = mock.calls.GetSegmentUsers
+	mock.lockGetSegmentUsers.RUnlock()
+	return calls
+}
+
+// GetSegments calls GetSegmentsFunc.
+func (mock *RepoMock) GetSegments(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error) {
+	if mock.GetSegmentsFunc == nil {
+		panic("RepoMock.GetSegmentsFunc: method is nil but Repo.GetSegments was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter *model.SegmentsFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockGetSegments.Lock()
+	mock.calls.GetSegments = append(mock.calls.GetSegments, callInfo)
+	mock.lockGetSegments.Unlock()
+	return mock.GetSegmentsFunc(ctx, filter)
+}
+
+// GetSegmentsCalls gets all the calls that were made to GetSegments.
+// Check the length with:
+//
+//	len(mockedRepo.GetSegmentsCalls())
+func (mock *RepoMock) GetSegmentsCalls() []struct {
+	Ctx    context.Context
+	Filter *model.SegmentsFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter *model.SegmentsFilter
+	}
+	mock.lockGetSegments.RLock()
+	calls = mock.calls.GetSegments
+	mock.lockGetSegments.RUnlock()
+	return calls
+}
+
+// PurgeSegment calls PurgeSegmentFunc.
+func (mock *RepoMock) PurgeSegment(ctx context.Context, slug string) error {
+	if mock.PurgeSegmentFunc == nil {
+		panic("RepoMock.PurgeSegmentFunc: method is nil but Repo.PurgeSegment was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Slug string
+	}{
+		Ctx:  ctx,
+		Slug: slug,
+	}
+	mock.lockPurgeSegment.Lock()
+	mock.calls.PurgeSegment = append(mock.calls.PurgeSegment, callInfo)
+	mock.lockPurgeSegment.Unlock()
+	return mock.PurgeSegmentFunc(ctx, slug)
+}
+
+// PurgeSegmentCalls gets all the calls that were made to PurgeSegment.
+// Check the length with:
+//
+//	len(mockedRepo.PurgeSegmentCalls())
+func (mock *RepoMock) PurgeSegmentCalls() []struct {
+	Ctx  context.Context
+	Slug string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Slug string
+	}
+	mock.lockPurgeSegment.RLock()
+	calls = mock.calls.PurgeSegment
+	mock.lockPurgeSegment.RUnlock()
+	return calls
+}
+
+// UpdateSegment calls UpdateSegmentFunc.
+func (mock *RepoMock) UpdateSegment(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+	if mock.UpdateSegmentFunc == nil {
+		panic("RepoMock.UpdateSegmentFunc: method is nil but Repo.UpdateSegment was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Slug   string
+		Update *model.SegmentUpdate
+	}{
+		Ctx:    ctx,
+		Slug:   slug,
+		Update: update,
+	}
+	mock.lockUpdateSegment.Lock()
+	mock.calls.UpdateSegment = append(mock.calls.UpdateSegment, callInfo)
+	mock.lockUpdateSegment.Unlock()
+	return mock.UpdateSegmentFunc(ctx, slug, update)
+}
+
+// UpdateSegmentCalls gets all the calls that were made to UpdateSegment.
+// Check the length with:
+//
+//	len(mockedRepo.UpdateSegmentCalls())
+func (mock *RepoMock) UpdateSegmentCalls() []struct {
+	Ctx    context.Context
+	Slug   string
+	Update *model.SegmentUpdate
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Slug   string
+		Update *model.SegmentUpdate
+	}
+	mock.lockUpdateSegment.RLock()
+	calls = mock.calls.UpdateSegment
+	mock.lockUpdateSegment.RUnlock()
+	return calls
+}
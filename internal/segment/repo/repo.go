@@ -2,22 +2,65 @@ package repo
 
 import (
 	"context"
-	
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"avito_2023/internal/database"
+	"avito_2023/internal/segment/bucket"
 	"avito_2023/internal/segment/model"
 	uModel "avito_2023/internal/user/model"
 )
 
+// segmentColumns selects segment info with the number of its active members
+var segmentColumns = []string{
+	"segments.id",
+	"segments.slug",
+	"segments.description",
+	"segments.owner_team",
+	"segments.percentage",
+	"segments.default_ttl_seconds",
+	"segments.created_at",
+	"segments.updated_at",
+	"segments.archived_at",
+	membersCountColumn,
+}
+
+// membersCountColumn selects the number of active segment members
+const membersCountColumn = "(SELECT COUNT(DISTINCT user_id) FROM users_segments WHERE users_segments.segment_id = segments.id " +
+	"AND (users_segments.active_from IS NULL OR users_segments.active_from <= NOW()) " +
+	"AND (users_segments.deleted_at IS NULL OR users_segments.deleted_at > NOW())) AS members_count"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 //go:generate moq --out mocks/repo_mock.go --pkg=mocks . Repo
 
 type Repo interface {
 	// AddSegment - add new segment
-	AddSegment(ctx context.Context, slug string, percentage uint) error
+	AddSegment(ctx context.Context, segment *model.SegmentDB) error
 
-	// DeleteSegment - delete segment
+	// DeleteSegment - archive segment keeping its memberships and history
 	DeleteSegment(ctx context.Context, slug string) error
+
+	// PurgeSegment - permanently delete archived segment with its memberships
+	PurgeSegment(ctx context.Context, slug string) error
+
+	// GetSegments - get segments with slug starting with prefix
+	GetSegments(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error)
+
+	// GetSegment - get segment by slug
+	GetSegment(ctx context.Context, slug string) (*model.Segment, error)
+
+	// GetSegmentUsers - get segment members with their latest membership period
+	GetSegmentUsers(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error)
+
+	// UpdateSegment - update segment info and ramp its auto percentage
+	UpdateSegment(ctx context.Context, slug string, update *model.SegmentUpdate) error
+
+	// CountActiveMembers - get number of active members of every not archived segment
+	CountActiveMembers(ctx context.Context) (map[string]uint, error)
 }
 
 type repo struct {
@@ -25,53 +68,115 @@ type repo struct {
 }
 
 func NewRepo(db *gorm.DB) Repo {
-	return &repo{
-		db: db,
+	return &instrumentedRepo{
+		repo: &repo{
+			db: db,
+		},
 	}
 }
 
-func (r *repo) AddSegment(ctx context.Context, slug string, percentage uint) error {
+func (r *repo) AddSegment(ctx context.Context, segment *model.SegmentDB) error {
 	db := database.FromContext(ctx, r.db)
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		newSegment := &model.SegmentDB{Slug: slug}
+		salt, err := bucket.NewSalt()
+		if err != nil {
+			return err
+		}
+
+		newSegment := &model.SegmentDB{
+			Slug:        segment.Slug,
+			Description: segment.Description,
+			OwnerTeam:   segment.OwnerTeam,
+			Percentage:  segment.Percentage,
+			Salt:        salt,
+
+			DefaultTTLSeconds: segment.DefaultTTLSeconds,
+		}
 		if err := tx.Create(newSegment).Error; err != nil {
+			if database.IsUniqueViolationErr(err) {
+				return database.ErrAddSegment_AlreadyExists
+			}
 			return err
 		}
 
-		if percentage == 0 {
+		if newSegment.Percentage == 0 {
 			return nil
 		}
 
-		// get random users ids
-		var usersCount int64
+		// existing users falling into the percentage, new users are assigned on creation
+		var usersIDs []uint
 		if err := tx.Model(&uModel.UserDB{}).
-			Count(&usersCount).Error; err != nil {
+			Select("id").
+			Where(bucket.Expr("id", "?")+" < ?", salt, newSegment.Percentage).
+			Find(&usersIDs).Error; err != nil {
+			return err
+		}
+
+		return addUsers(tx, newSegment, usersIDs, uModel.SourcePercentage)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) DeleteSegment(ctx context.Context, slug string) error {
+	db := database.FromContext(ctx, r.db)
+
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var segment *model.SegmentDB
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(slug) = LOWER(?) AND archived_at IS NULL", slug).
+			First(&segment).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(segment).
+			Update("archived_at", gorm.Expr("NOW()")).Error; err != nil {
 			return err
 		}
-		limit := int(usersCount * int64(percentage) / 100)
 
+		// scheduled memberships never started, so they are dropped without history
+		if err := tx.Where("segment_id = ? AND active_from > NOW()", segment.ID).
+			Delete(&uModel.UserSegmentDB{}).Error; err != nil {
+			return err
+		}
+
+		// memberships are kept, but end at the moment of archiving
 		var usersIDs []uint
-		if err := tx.Model(&uModel.UserDB{}).
-			Select("id").
-			Order("RANDOM()").
-			Limit(limit).
+		if err := tx.Model(&uModel.UserSegmentDB{}).
+			Select("user_id").
+			Where("segment_id = ?", segment.ID).
+			Where("deleted_at IS NULL OR deleted_at > NOW()").
 			Find(&usersIDs).Error; err != nil {
 			return err
 		}
 
-		newUsersSegments := make([]*uModel.UserSegmentDB, len(usersIDs))
-		for i, userID := range usersIDs {
-			newUsersSegments[i] = &uModel.UserSegmentDB{
-				UserID:    userID,
-				SegmentID: newSegment.ID,
-			}
-		}
-		if err := tx.Model(&uModel.UserSegmentDB{}).Create(&newUsersSegments).Error; err != nil {
+		return removeUsers(tx, segment, usersIDs, uModel.SourceArchive)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) PurgeSegment(ctx context.Context, slug string) error {
+	db := database.FromContext(ctx, r.db)
+
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var segment *model.SegmentDB
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(slug) = LOWER(?)", slug).
+			First(&segment).Error; err != nil {
 			return err
 		}
+		if segment.ArchivedAt == nil {
+			return database.ErrPurgeSegment_NotArchived
+		}
 
-		return nil
+		// memberships are removed by cascade, history events lose the link but keep the slug
+		return tx.Delete(segment).Error
 	}); err != nil {
 		return err
 	}
@@ -79,16 +184,219 @@ func (r *repo) AddSegment(ctx context.Context, slug string, percentage uint) err
 	return nil
 }
 
-func (r *repo) DeleteSegment(ctx context.Context, slug string) error {
+func (r *repo) GetSegments(ctx context.Context, filter *model.SegmentsFilter) ([]*model.Segment, error) {
+	db := database.FromContext(ctx, r.db)
+
+	query := db.WithContext(ctx).
+		Model(&model.SegmentDB{}).
+		Select(segmentColumns)
+	if filter.Prefix != "" {
+		query = query.Where("LOWER(slug) LIKE ?", likeEscaper.Replace(strings.ToLower(filter.Prefix))+"%")
+	}
+	if !filter.Archived {
+		query = query.Where("archived_at IS NULL")
+	}
+
+	var segments []*model.Segment
+	if err := query.Order("slug").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Scan(&segments).Error; err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+func (r *repo) GetSegment(ctx context.Context, slug string) (*model.Segment, error) {
 	db := database.FromContext(ctx, r.db)
 
-	res := db.WithContext(ctx).
-		Delete(&model.SegmentDB{}, "slug = ?", slug)
-	if res.Error != nil {
-		return res.Error
+	var segments []*model.Segment
+	if err := db.WithContext(ctx).
+		Model(&model.SegmentDB{}).
+		Select(segmentColumns).
+		Where("LOWER(slug) = LOWER(?)", slug).
+		Scan(&segments).Error; err != nil {
+		return nil, err
 	}
-	if res.RowsAffected == 0 {
-		return database.ErrNotFound
+	if len(segments) == 0 {
+		return nil, database.ErrNotFound
 	}
+
+	return segments[0], nil
+}
+
+func (r *repo) GetSegmentUsers(ctx context.Context, slug string, filter *model.MembersFilter) ([]*model.Member, error) {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	var segment *model.SegmentDB
+	if err := db.Select("id").
+		Where("LOWER(slug) = LOWER(?)", slug).
+		First(&segment).Error; err != nil {
+		return nil, err
+	}
+
+	// the latest period of each user decides whether the member is active or expired
+	periods := db.Model(&uModel.UserSegmentDB{}).
+		Select("DISTINCT ON (user_id) user_id", "source", "COALESCE(active_from, created_at) AS joined_at", "deleted_at AS delete_at",
+			"(active_from IS NULL OR active_from <= NOW()) AND (deleted_at IS NULL OR deleted_at > NOW()) AS active").
+		Where("segment_id = ? AND user_id > ?", segment.ID, filter.After).
+		Order("user_id, created_at DESC")
+
+	query := db.Table("(?) AS members", periods)
+	switch filter.Status {
+	case model.MemberStatusActive:
+		query = query.Where("active")
+	case model.MemberStatusExpired:
+		query = query.Where("NOT active AND joined_at <= NOW()")
+	case model.MemberStatusScheduled:
+		query = query.Where("joined_at > NOW()")
+	}
+
+	var members []*model.Member
+	if err := query.Order("user_id").
+		Limit(filter.Limit).
+		Scan(&members).Error; err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *repo) UpdateSegment(ctx context.Context, slug string, update *model.SegmentUpdate) error {
+	db := database.FromContext(ctx, r.db)
+
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var segment *model.SegmentDB
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(slug) = LOWER(?) AND archived_at IS NULL", slug).
+			First(&segment).Error; err != nil {
+			return err
+		}
+		oldPercentage, newPercentage := segment.Percentage, segment.Percentage
+
+		values := make(map[string]interface{})
+		if update.Description != nil {
+			values["description"] = *update.Description
+		}
+		if update.OwnerTeam != nil {
+			values["owner_team"] = *update.OwnerTeam
+		}
+		if update.Percentage != nil {
+			newPercentage = *update.Percentage
+			values["percentage"] = newPercentage
+		}
+		if update.DefaultTTLSeconds != nil {
+			values["default_ttl_seconds"] = *update.DefaultTTLSeconds
+		}
+		if err := tx.Model(segment).Updates(values).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case newPercentage > oldPercentage:
+			// users of the new buckets join, existing members stay
+			var usersIDs []uint
+			if err := tx.Model(&uModel.UserDB{}).
+				Select("id").
+				Where(bucket.Expr("id", "?")+" >= ? AND "+bucket.Expr("id", "?")+" < ?",
+					segment.Salt, oldPercentage, segment.Salt, newPercentage).
+				Where("NOT EXISTS (SELECT 1 FROM users_segments WHERE users_segments.user_id = users.id "+
+					"AND users_segments.segment_id = ? AND (users_segments.deleted_at IS NULL OR users_segments.deleted_at > NOW()))", segment.ID).
+				Find(&usersIDs).Error; err != nil {
+				return err
+			}
+
+			return addUsers(tx, segment, usersIDs, uModel.SourcePercentage)
+		case newPercentage < oldPercentage:
+			// only auto assigned members of the dropped buckets leave
+			var usersIDs []uint
+			if err := tx.Model(&uModel.UserSegmentDB{}).
+				Select("user_id").
+				Where("segment_id = ? AND source = ?", segment.ID, uModel.SourcePercentage).
+				Where("deleted_at IS NULL OR deleted_at > NOW()").
+				Where(bucket.Expr("user_id", "?")+" >= ?", segment.Salt, newPercentage).
+				Find(&usersIDs).Error; err != nil {
+				return err
+			}
+
+			return removeUsers(tx, segment, usersIDs, uModel.SourcePercentage)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// addUsers opens segment memberships for the users and records them in history
+func addUsers(tx *gorm.DB, segment *model.SegmentDB, usersIDs []uint, source string) error {
+	if len(usersIDs) == 0 {
+		return nil
+	}
+
+	deleteAt := segment.DefaultDeleteAt(time.Now())
+	newUsersSegments := make([]*uModel.UserSegmentDB, len(usersIDs))
+	events := make([]*uModel.SegmentEventDB, len(usersIDs))
+	for i, userID := range usersIDs {
+		newUsersSegments[i] = &uModel.UserSegmentDB{
+			UserID:    userID,
+			SegmentID: segment.ID,
+			DeletedAt: deleteAt,
+			Source:    source,
+		}
+		events[i] = uModel.NewSegmentEvent(userID, segment, uModel.OperationAdd, source)
+	}
+	if err := tx.Model(&uModel.UserSegmentDB{}).CreateInBatches(&newUsersSegments, database.InsertBatchSize).Error; err != nil {
+		return err
+	}
+
+	return tx.CreateInBatches(&events, database.InsertBatchSize).Error
+}
+
+// removeUsers closes active segment memberships of the users and records them in history
+func removeUsers(tx *gorm.DB, segment *model.SegmentDB, usersIDs []uint, source string) error {
+	if len(usersIDs) == 0 {
+		return nil
+	}
+
+	events := make([]*uModel.SegmentEventDB, len(usersIDs))
+	for i, userID := range usersIDs {
+		events[i] = uModel.NewSegmentEvent(userID, segment, uModel.OperationRemove, source)
+	}
+	for start := 0; start < len(usersIDs); start += database.InsertBatchSize {
+		end := min(start+database.InsertBatchSize, len(usersIDs))
+		if err := tx.Model(&uModel.UserSegmentDB{}).
+			Where("segment_id = ? AND user_id IN ?", segment.ID, usersIDs[start:end]).
+			Where("deleted_at IS NULL OR deleted_at > NOW()").
+			Updates(map[string]interface{}{"deleted_at": gorm.Expr("NOW()"), "finalized": true}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.CreateInBatches(&events, database.InsertBatchSize).Error
+}
+
+func (r *repo) CountActiveMembers(ctx context.Context) (map[string]uint, error) {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	var rows []struct {
+		Slug         string
+		MembersCount uint
+	}
+	if err := db.Model(&model.SegmentDB{}).
+		Select("segments.slug", membersCountColumn).
+		Where("segments.archived_at IS NULL").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		counts[row.Slug] = row.MembersCount
+	}
+
+	return counts, nil
+}
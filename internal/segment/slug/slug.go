@@ -0,0 +1,128 @@
+package slug
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Tag is the binding tag checking a new segment slug against the registered policy.
+// Slugs referring to existing segments are only limited by MaxLength, so segments created
+// before the policy or its changes stay reachable.
+const Tag = "slug"
+
+// MaxLength is the size of segments slug column
+const MaxLength = 50
+
+// Case normalizations applied to new slugs
+const (
+	CaseKeep  = "keep"
+	CaseUpper = "upper"
+	CaseLower = "lower"
+)
+
+type Options struct {
+	// Charset is the content of a regexp character class, e.g. A-Z0-9_
+	Charset   string
+	MinLength int
+	MaxLength int
+	Case      string
+	// ReservedPrefixes are compared case-insensitively
+	ReservedPrefixes []string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Charset:   "A-Za-z0-9_-",
+		MinLength: 1,
+		MaxLength: MaxLength,
+		Case:      CaseKeep,
+	}
+}
+
+// Policy defines which slugs segments may have
+type Policy struct {
+	charset  *regexp.Regexp
+	options  Options
+	reserved []string
+}
+
+func NewPolicy(o Options) (*Policy, error) {
+	var errs []error
+
+	charset, err := regexp.Compile("^[" + o.Charset + "]+$")
+	if o.Charset == "" || err != nil {
+		errs = append(errs, fmt.Errorf("slug charset %q is not a valid character class", o.Charset))
+	}
+	if o.MinLength < 1 || o.MaxLength > MaxLength || o.MinLength > o.MaxLength {
+		errs = append(errs, fmt.Errorf("slug length must be within 1 and %d and min must not exceed max", MaxLength))
+	}
+	switch o.Case {
+	case CaseKeep, CaseUpper, CaseLower:
+	default:
+		errs = append(errs, fmt.Errorf("slug case %q is not one of keep, upper, lower", o.Case))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	reserved := make([]string, 0, len(o.ReservedPrefixes))
+	for _, prefix := range o.ReservedPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			reserved = append(reserved, strings.ToLower(prefix))
+		}
+	}
+
+	return &Policy{
+		charset:  charset,
+		options:  o,
+		reserved: reserved,
+	}, nil
+}
+
+// Normalize brings slug to the policy case
+func (p *Policy) Normalize(slug string) string {
+	switch p.options.Case {
+	case CaseUpper:
+		return strings.ToUpper(slug)
+	case CaseLower:
+		return strings.ToLower(slug)
+	default:
+		return slug
+	}
+}
+
+// Validate checks normalized slug against the policy
+func (p *Policy) Validate(slug string) error {
+	if n := utf8.RuneCountInString(slug); n < p.options.MinLength || n > p.options.MaxLength {
+		return fmt.Errorf("slug must be %d to %d characters long", p.options.MinLength, p.options.MaxLength)
+	}
+	if !p.charset.MatchString(slug) {
+		return fmt.Errorf("slug must consist of [%s] characters", p.options.Charset)
+	}
+	lower := strings.ToLower(slug)
+	for _, prefix := range p.reserved {
+		if strings.HasPrefix(lower, prefix) {
+			return fmt.Errorf("slug prefix %s is reserved", prefix)
+		}
+	}
+
+	return nil
+}
+
+// RegisterValidation makes the policy available to gin binding under Tag
+func (p *Policy) RegisterValidation() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin binding validator is not go-playground validator")
+	}
+
+	return v.RegisterValidation(Tag, func(fl validator.FieldLevel) bool {
+		return p.Validate(p.Normalize(fl.Field().String())) == nil
+	})
+}
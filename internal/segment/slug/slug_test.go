@@ -0,0 +1,66 @@
+package slug_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avito_2023/internal/segment/slug"
+)
+
+func TestValidate(t *testing.T) {
+	options := slug.DefaultOptions()
+	options.Case = slug.CaseUpper
+	options.ReservedPrefixes = []string{"SYS_", " "}
+	policy, err := slug.NewPolicy(options)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		slug       string
+		normalized string
+		expected   string
+	}{
+		{name: "valid", slug: "AVITO_VOICE_MESSAGES", normalized: "AVITO_VOICE_MESSAGES"},
+		{name: "normalized case", slug: "avito_voice_messages", normalized: "AVITO_VOICE_MESSAGES"},
+		{name: "empty", slug: "", normalized: "", expected: "slug must be 1 to 50 characters long"},
+		{name: "too long", slug: strings.Repeat("A", 51), normalized: strings.Repeat("A", 51), expected: "slug must be 1 to 50 characters long"},
+		{name: "space", slug: "AVITO VOICE", normalized: "AVITO VOICE", expected: "slug must consist of [A-Za-z0-9_-] characters"},
+		{name: "reserved prefix", slug: "sys_internal", normalized: "SYS_INTERNAL", expected: "slug prefix sys_ is reserved"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized := policy.Normalize(tc.slug)
+			assert.Equal(t, tc.normalized, normalized)
+
+			err := policy.Validate(normalized)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNewPolicyInvalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options slug.Options
+	}{
+		{name: "invalid charset", options: slug.Options{Charset: "Z-A", MinLength: 1, MaxLength: 10, Case: slug.CaseKeep}},
+		{name: "length above column size", options: slug.Options{Charset: "A-Z", MinLength: 1, MaxLength: 51, Case: slug.CaseKeep}},
+		{name: "min above max", options: slug.Options{Charset: "A-Z", MinLength: 10, MaxLength: 5, Case: slug.CaseKeep}},
+		{name: "unknown case", options: slug.Options{Charset: "A-Z", MinLength: 1, MaxLength: 10, Case: "title"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := slug.NewPolicy(tc.options)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"avito_2023/internal/database"
+)
+
+const spanKey = "tracing:span"
+
+// gormPlugin starts a client span for every statement gorm runs
+type gormPlugin struct {
+	tracer trace.Tracer
+}
+
+// GormPlugin returns gorm plugin tracing statements as children of the span in their context,
+// so statements of a repo method are nested in the method span
+func GormPlugin(tp trace.TracerProvider) gorm.Plugin {
+	return &gormPlugin{tracer: tp.Tracer(instrumentationName)}
+}
+
+func (p *gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	return database.RegisterStatementCallbacks(db, p.Name(), p.before, p.after)
+}
+
+func (p *gormPlugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		_, span := p.tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func (p *gormPlugin) after(string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span, ok := v.(trace.Span)
+		if !ok {
+			return
+		}
+		defer span.End()
+
+		// SQL keeps placeholders, so bound values don't leak to traces
+		query := db.Statement.SQL.String()
+		operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+		operation = strings.ToUpper(operation)
+		if operation != "" {
+			name := operation
+			if db.Statement.Table != "" {
+				name += " " + db.Statement.Table
+				span.SetAttributes(semconv.DBCollectionName(db.Statement.Table))
+			}
+			span.SetName(name)
+			span.SetAttributes(semconv.DBOperationName(operation))
+		}
+		span.SetAttributes(semconv.DBQueryText(query))
+		if operation == "SELECT" {
+			span.SetAttributes(semconv.DBResponseReturnedRows(int(db.Statement.RowsAffected)))
+		}
+
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			recordError(span, db.Error)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"avito_2023/internal/database"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	instrumentationName = "avito_2023/internal/tracing"
+)
+
+type Options struct {
+	// Exporter is one of none, stdout, otlp
+	Exporter    string
+	ServiceName string
+	// Endpoint is OTLP/HTTP collector host:port, empty means OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318
+	Endpoint string
+	// Insecure sends spans to the collector over plain http
+	Insecure bool
+}
+
+// Validate checks exporter is known
+func (o Options) Validate() error {
+	switch o.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+		return nil
+	default:
+		return fmt.Errorf("tracing exporter %q is not one of none, stdout, otlp", o.Exporter)
+	}
+}
+
+// Propagator propagates W3C trace context and baggage
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// NewProvider returns tracer provider batching spans to the configured exporter, nil for none exporter.
+// Sampler is taken from OTEL_TRACES_SAMPLER, every span is sampled by default.
+func NewProvider(ctx context.Context, opts Options) (*sdktrace.TracerProvider, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		stdout, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		exporter = stdout
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		otlp, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = otlp
+	}
+
+	// env attributes go last, so OTEL_SERVICE_NAME overrides configured name
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(opts.ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// Middleware starts a server span for every request continuing trace of the caller
+func Middleware(service string, tp trace.TracerProvider) gin.HandlerFunc {
+	return otelgin.Middleware(service,
+		otelgin.WithTracerProvider(tp),
+		otelgin.WithPropagators(Propagator()),
+	)
+}
+
+// MethodHook starts a span for every repo method, see database.AddMethodHook
+func MethodHook(tp trace.TracerProvider) database.MethodHook {
+	tracer := tp.Tracer(instrumentationName)
+
+	return func(ctx context.Context, method string) (context.Context, func(err error)) {
+		ctx, span := tracer.Start(ctx, method)
+
+		return ctx, func(err error) {
+			if err != nil {
+				recordError(span, err)
+			}
+			span.End()
+		}
+	}
+}
+
+// recordError marks span failed on internal errors only,
+// client errors like not found are expected and just annotate the span
+func recordError(span trace.Span, err error) {
+	coded := database.Classify(err)
+	span.SetAttributes(semconv.ErrorTypeKey.String(string(coded.Code)))
+	if coded.Code == database.CodeInternal {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
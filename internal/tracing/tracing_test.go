@@ -0,0 +1,133 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/tracing"
+)
+
+func newProvider() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		values[kv.Key] = kv.Value
+	}
+
+	return values
+}
+
+func TestMiddleware(t *testing.T) {
+	tp, recorder := newProvider()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(tracing.Middleware("segments", tp))
+	var handlerSpan trace.SpanContext
+	r.GET("/user/:id", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/1000", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /user/:id", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+}
+
+func TestMethodHook(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedCode   string
+		expectedStatus codes.Code
+	}{
+		{
+			name:           "success",
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "not found",
+			err:            database.ErrNotFound,
+			expectedCode:   "not_found",
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "internal",
+			err:            errors.New("connection refused"),
+			expectedCode:   "internal_error",
+			expectedStatus: codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tp, recorder := newProvider()
+
+			_, end := tracing.MethodHook(tp)(context.Background(), "user.GetUserProfile")
+			end(tc.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "user.GetUserProfile", spans[0].Name())
+			assert.Equal(t, tc.expectedStatus, spans[0].Status().Code)
+			assert.Equal(t, tc.expectedCode, attributes(spans[0])["error.type"].AsString())
+		})
+	}
+}
+
+func TestGormPlugin(t *testing.T) {
+	tp, recorder := newProvider()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(tracing.GormPlugin(tp)))
+
+	ctx, method := tp.Tracer("test").Start(context.Background(), "segment.GetSegment")
+	var slugs []string
+	require.NoError(t, db.WithContext(ctx).Table("segments").Where("slug = ?", "AVITO_VOICE_MESSAGES").Pluck("slug", &slugs).Error)
+	method.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	statement := spans[0]
+	assert.Equal(t, "SELECT segments", statement.Name())
+	assert.Equal(t, trace.SpanKindClient, statement.SpanKind())
+	assert.Equal(t, method.SpanContext().SpanID(), statement.Parent().SpanID())
+
+	attrs := attributes(statement)
+	assert.Equal(t, "postgresql", attrs["db.system.name"].AsString())
+	assert.Equal(t, "SELECT", attrs["db.operation.name"].AsString())
+	assert.Equal(t, "segments", attrs["db.collection.name"].AsString())
+	// bound values are not exported
+	assert.Equal(t, `SELECT "slug" FROM "segments" WHERE slug = $1`, attrs["db.query.text"].AsString())
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"avito_2023/internal/auth"
+	"avito_2023/internal/ttl/worker"
+)
+
+type StatusProvider interface {
+	Status() worker.Status
+}
+
+type Handler struct {
+	worker StatusProvider
+}
+
+// @Summary Get TTL Worker Status
+// @Tags ttl
+// @Description Get last run status of the background TTL expiration worker
+// @Produce json
+// @Success 200 {object} worker.Status
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /ttl/status [get]
+func (h *Handler) getStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.worker.Status())
+}
+
+func NewHandler(worker StatusProvider) *Handler {
+	return &Handler{
+		worker: worker,
+	}
+}
+
+func Route(r *gin.Engine, h *Handler, guard auth.Guard) {
+	router := r.Group("ttl")
+
+	{
+		router.GET("/status", guard.Require(auth.RoleReader), h.getStatus)
+	}
+}
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"avito_2023/internal/user/repo"
+)
+
+const (
+	DefaultInterval  = 30 * time.Second
+	DefaultBatchSize = 500
+)
+
+// Status describes the last run of the worker
+type Status struct {
+	Running        bool       `json:"running"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastSuccessAt  *time.Time `json:"last_success_at"`
+	LastDuration   string     `json:"last_duration"`
+	LastActivated  int        `json:"last_activated"`
+	LastExpired    int        `json:"last_expired"`
+	LastError      string     `json:"last_error,omitempty"`
+	TotalActivated uint64     `json:"total_activated"`
+	TotalExpired   uint64     `json:"total_expired"`
+	// OldestDueAt is the start or TTL of the oldest membership left unprocessed after the last run
+	OldestDueAt *time.Time `json:"oldest_due_at"`
+	// LagSeconds is the age of OldestDueAt at the end of the last run, it grows while the worker fails
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// Worker periodically records starts of scheduled memberships,
+// finalizes memberships with passed TTL and records their expiration
+type Worker struct {
+	repo      repo.Repo
+	interval  time.Duration
+	batchSize int
+
+	mu     sync.RWMutex
+	status Status
+}
+
+func NewWorker(repo repo.Repo, interval time.Duration, batchSize int) *Worker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Worker{
+		repo:      repo,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run processes due memberships every interval until ctx is done, it returns once the current batch is completed
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce records starts of scheduled memberships and finalizes all memberships due at the moment in batches
+func (w *Worker) RunOnce(ctx context.Context) {
+	w.mu.Lock()
+	w.status.Running = true
+	w.mu.Unlock()
+
+	// a started batch is completed on shutdown, cancellation only prevents the next one
+	batchCtx := context.WithoutCancel(ctx)
+
+	start := time.Now()
+	activated, err := w.drain(ctx, func() (int, error) {
+		rows, err := w.repo.ActivateUserSegments(batchCtx, w.batchSize)
+		return len(rows), err
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "ttl worker: failed to activate user segments", "error", err)
+	}
+
+	var expired int
+	if err == nil {
+		expired, err = w.drain(ctx, func() (int, error) {
+			rows, err := w.repo.ExpireUserSegments(batchCtx, w.batchSize)
+			return len(rows), err
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "ttl worker: failed to expire user segments", "error", err)
+		}
+	}
+
+	// backlog is measured even if the run failed, that's when it grows
+	oldestDueAt, dueErr := w.repo.GetOldestDue(batchCtx)
+	if dueErr != nil {
+		slog.ErrorContext(ctx, "ttl worker: failed to get oldest due membership", "error", dueErr)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.status.Running = false
+	w.status.LastRunAt = &start
+	w.status.LastDuration = now.Sub(start).String()
+	w.status.LastActivated = activated
+	w.status.TotalActivated += uint64(activated)
+	w.status.LastExpired = expired
+	w.status.TotalExpired += uint64(expired)
+	// the previous oldest due membership is kept if backlog is unknown, so lag keeps growing
+	if dueErr == nil {
+		w.status.OldestDueAt = oldestDueAt
+	}
+	w.status.LagSeconds = 0
+	if w.status.OldestDueAt != nil {
+		w.status.LagSeconds = max(now.Sub(*w.status.OldestDueAt), 0).Seconds()
+	}
+	w.status.LastError = ""
+	if err != nil {
+		w.status.LastError = err.Error()
+	} else {
+		w.status.LastSuccessAt = &start
+	}
+}
+
+// drain calls batch until it returns less rows than the batch size
+func (w *Worker) drain(ctx context.Context, batch func() (int, error)) (int, error) {
+	var total int
+	for ctx.Err() == nil {
+		n, err := batch()
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+
+		if n < w.batchSize {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// Status returns the last run status
+func (w *Worker) Status() Status {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.status
+}
@@ -0,0 +1,208 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"avito_2023/internal/ttl/worker"
+	"avito_2023/internal/user/model"
+	"avito_2023/internal/user/repo/mocks"
+)
+
+func TestRunOnce(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name            string
+		batches         [][]*model.ExpiredUserSegment
+		err             error
+		expectedCalls   int
+		expectedExpired int
+		expectedError   string
+	}{
+		{
+			name:            "nothing to expire",
+			batches:         [][]*model.ExpiredUserSegment{nil},
+			expectedCalls:   1,
+			expectedExpired: 0,
+		},
+		{
+			name: "drain several batches",
+			batches: [][]*model.ExpiredUserSegment{
+				{{ID: 1, DeletedAt: now.Add(-time.Minute)}, {ID: 2, DeletedAt: now}},
+				{{ID: 3, DeletedAt: now}, {ID: 4, DeletedAt: now}},
+				{{ID: 5, DeletedAt: now}},
+			},
+			expectedCalls:   3,
+			expectedExpired: 5,
+		},
+		{
+			name:          "failed to expire",
+			err:           fmt.Errorf("something went wrong"),
+			expectedCalls: 1,
+			expectedError: "something went wrong",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &mocks.RepoMock{}
+			repo.ActivateUserSegmentsFunc = func(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error) {
+				return nil, nil
+			}
+			repo.ExpireUserSegmentsFunc = func(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error) {
+				if tc.err != nil {
+					return nil, tc.err
+				}
+				call := len(repo.ExpireUserSegmentsCalls()) - 1
+				return tc.batches[call], nil
+			}
+			repo.GetOldestDueFunc = func(ctx context.Context) (*time.Time, error) {
+				return nil, nil
+			}
+
+			w := worker.NewWorker(repo, time.Minute, 2)
+			w.RunOnce(context.Background())
+
+			status := w.Status()
+			assert.Len(t, repo.ExpireUserSegmentsCalls(), tc.expectedCalls)
+			assert.Equal(t, tc.expectedExpired, status.LastExpired)
+			assert.Equal(t, uint64(tc.expectedExpired), status.TotalExpired)
+			assert.Equal(t, tc.expectedError, status.LastError)
+			assert.False(t, status.Running)
+			assert.NotNil(t, status.LastRunAt)
+		})
+	}
+}
+
+func TestRunOnceActivate(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name              string
+		batches           [][]*model.ActivatedUserSegment
+		err               error
+		expectedCalls     int
+		expectedExpCalls  int
+		expectedActivated int
+		expectedError     string
+	}{
+		{
+			name: "drain several batches",
+			batches: [][]*model.ActivatedUserSegment{
+				{{ID: 1, ActiveFrom: now.Add(-time.Minute)}, {ID: 2, ActiveFrom: now}},
+				{{ID: 3, ActiveFrom: now}},
+			},
+			expectedCalls:     2,
+			expectedExpCalls:  1,
+			expectedActivated: 3,
+		},
+		{
+			name:             "failed to activate",
+			err:              fmt.Errorf("something went wrong"),
+			expectedCalls:    1,
+			expectedExpCalls: 0,
+			expectedError:    "something went wrong",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &mocks.RepoMock{}
+			repo.ActivateUserSegmentsFunc = func(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error) {
+				if tc.err != nil {
+					return nil, tc.err
+				}
+				call := len(repo.ActivateUserSegmentsCalls()) - 1
+				return tc.batches[call], nil
+			}
+			repo.ExpireUserSegmentsFunc = func(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error) {
+				return nil, nil
+			}
+			repo.GetOldestDueFunc = func(ctx context.Context) (*time.Time, error) {
+				return nil, nil
+			}
+
+			w := worker.NewWorker(repo, time.Minute, 2)
+			w.RunOnce(context.Background())
+
+			status := w.Status()
+			assert.Len(t, repo.ActivateUserSegmentsCalls(), tc.expectedCalls)
+			assert.Len(t, repo.ExpireUserSegmentsCalls(), tc.expectedExpCalls)
+			assert.Equal(t, tc.expectedActivated, status.LastActivated)
+			assert.Equal(t, uint64(tc.expectedActivated), status.TotalActivated)
+			assert.Equal(t, tc.expectedError, status.LastError)
+		})
+	}
+}
+
+func TestRunOnceLag(t *testing.T) {
+	now := time.Now()
+	oldest := now.Add(-10 * time.Minute)
+
+	testCases := []struct {
+		name            string
+		expireErr       error
+		oldestDueAt     *time.Time
+		dueErr          error
+		previousDueAt   *time.Time
+		expectedLag     time.Duration
+		expectedDueAt   *time.Time
+		expectedSuccess bool
+	}{
+		{
+			name:            "nothing due",
+			expectedSuccess: true,
+		},
+		{
+			name:            "backlog left after failed run",
+			expireErr:       fmt.Errorf("something went wrong"),
+			oldestDueAt:     &oldest,
+			expectedLag:     10 * time.Minute,
+			expectedDueAt:   &oldest,
+			expectedSuccess: false,
+		},
+		{
+			name:            "backlog unknown keeps previous oldest due",
+			expireErr:       fmt.Errorf("something went wrong"),
+			dueErr:          fmt.Errorf("something went wrong"),
+			previousDueAt:   &oldest,
+			expectedLag:     10 * time.Minute,
+			expectedDueAt:   &oldest,
+			expectedSuccess: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &mocks.RepoMock{}
+			repo.ActivateUserSegmentsFunc = func(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error) {
+				return nil, nil
+			}
+			repo.ExpireUserSegmentsFunc = func(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error) {
+				return nil, tc.expireErr
+			}
+
+			w := worker.NewWorker(repo, time.Minute, 2)
+			if tc.previousDueAt != nil {
+				repo.GetOldestDueFunc = func(ctx context.Context) (*time.Time, error) {
+					return tc.previousDueAt, nil
+				}
+				w.RunOnce(context.Background())
+			}
+			repo.GetOldestDueFunc = func(ctx context.Context) (*time.Time, error) {
+				return tc.oldestDueAt, tc.dueErr
+			}
+			w.RunOnce(context.Background())
+
+			status := w.Status()
+			assert.Equal(t, tc.expectedDueAt, status.OldestDueAt)
+			assert.InDelta(t, tc.expectedLag.Seconds(), status.LagSeconds, 5)
+			assert.Equal(t, tc.expectedSuccess, status.LastSuccessAt != nil)
+		})
+	}
+}
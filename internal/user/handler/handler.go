@@ -1,13 +1,24 @@
 package handler
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	aModel "avito_2023/internal/audit/model"
+	"avito_2023/internal/audit/recorder"
+	"avito_2023/internal/auth"
 	"avito_2023/internal/database"
+	"avito_2023/internal/response"
+	sModel "avito_2023/internal/segment/model"
+	"avito_2023/internal/user/model"
 	"avito_2023/internal/user/repo"
 )
 
@@ -17,36 +28,150 @@ type Handler struct {
 
 // @Summary Get User Segments
 // @Tags user
-// @Description Get active segments for specified user
+// @Description Get active segments for specified user, unknown user is not found while user without segments gets empty list
 // @Accept json
 // @Produce json
 // @Param user_id path int true "user ID"
 // @Success 200
-// @Failure 400
-// @Failure 404
-// @Failure 500
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /user/{user_id} [get]
 func (h *Handler) getUserSegments(c *gin.Context) {
 	var uri GetUserSegmentsUri
 	if err := c.ShouldBindUri(&uri); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Validation(c, err.Error())
 		return
 	}
 
 	segments, err := h.repo.GetUserSegments(c.Request.Context(), uri.UserID)
 	if err != nil {
 		if database.IsRecordNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("segments for user %d not found", uri.UserID)})
+			response.NotFound(c, fmt.Sprintf("user %d not found", uri.UserID))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.Error(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"user_id": uri.UserID, "segments": segments})
 }
 
+// @Summary Create User
+// @Tags user
+// @Description Register user with specified id, user is assigned to percentage segments it falls into
+// @Accept json
+// @Produce json
+// @Param body body CreateUserRequest true "user info"
+// @Success 201
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user [post]
+func (h *Handler) createUser(c *gin.Context) {
+	var body CreateUserRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	recorder.Users(c, body.UserID)
+
+	user, err := h.repo.CreateUser(c.Request.Context(), body.UserID)
+	if err != nil {
+		if database.IsCreateUserAlreadyExistsErr(err) {
+			response.Error(c, database.NewError(database.CodeAlreadyExists, fmt.Sprintf("user %d already exists", body.UserID)))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"user_id": user.ID, "created_at": user.CreatedAt})
+}
+
+// @Summary Get User Profile
+// @Tags user
+// @Description Get registered user with its active and scheduled segments
+// @Accept json
+// @Produce json
+// @Param user_id path int true "user ID"
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/{user_id}/profile [get]
+func (h *Handler) getUserProfile(c *gin.Context) {
+	var uri GetUserProfileUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	profile, err := h.repo.GetUserProfile(c.Request.Context(), uri.UserID)
+	if err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("user %d not found", uri.UserID))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Delete User
+// @Tags user
+// @Description Erase user with its memberships, history of the user is kept under a random pseudonym,
+// @Description the user is removed from audit log entries
+// @Accept json
+// @Produce json
+// @Param user_id path int true "user ID"
+// @Success 204
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/{user_id} [delete]
+func (h *Handler) deleteUser(c *gin.Context) {
+	var uri DeleteUserUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	// the user isn't recorded to audit log, erase removes it from past entries too
+	if err := h.repo.DeleteUser(c.Request.Context(), uri.UserID); err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("user %d not found", uri.UserID))
+			return
+		}
+
+		response.Error(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // @Summary Get User History
 // @Tags user
 // @Description Get user segments history
@@ -56,31 +181,35 @@ func (h *Handler) getUserSegments(c *gin.Context) {
 // @Param month query int true "month"
 // @Param year query int true "year"
 // @Success 200
-// @Failure 400
-// @Failure 404
-// @Failure 500
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /user/history/{user_id} [get]
 func (h *Handler) getUserHistory(c *gin.Context) {
 	var uri GetUserHistoryUri
 	if err := c.ShouldBindUri(&uri); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Validation(c, err.Error())
 		return
 	}
 
 	var query GetUserHistoryQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Validation(c, err.Error())
 		return
 	}
 
 	history, err := h.repo.GetUserHistory(c.Request.Context(), uri.UserID, query.Month, query.Year)
 	if err != nil {
 		if database.IsRecordNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("history for user %d not found", uri.UserID)})
+			response.NotFound(c, fmt.Sprintf("history for user %d not found", uri.UserID))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response.Error(c, err)
 		return
 	}
 
@@ -89,43 +218,353 @@ func (h *Handler) getUserHistory(c *gin.Context) {
 
 // @Summary Update User Segments
 // @Tags user
-// @Description Update user segments with specified slugs for specified user
+// @Description Update user segments with specified slugs for specified user and report the outcome per slug,
+// @Description slugs_to_add items are slugs or objects with own active_from and delete_at or ttl_seconds,
+// @Description in strict mode any unknown slug rejects the whole update
 // @Accept json
 // @Produce json
 // @Param body body UpdateUserSegmentsRequest true "user and segments info"
-// @Success 204
-// @Failure 400
-// @Failure 500
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /user/segment [put]
 func (h *Handler) updateUserSegments(c *gin.Context) {
 	var body UpdateUserSegmentsRequest
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Validation(c, err.Error())
 		return
 	}
+	recorder.Users(c, body.UserID)
+	recorder.Segments(c, body.SlugsToDel...)
+	for _, s := range body.SlugsToAdd {
+		recorder.Segments(c, s.Slug)
+	}
 
-	if body.DeleteAt != 0 && body.DeleteAt < time.Now().Unix() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value delete_at"})
+	slugsToAdd, err := parseSlugsToAdd(body.SlugsToAdd, body.ActiveFrom, body.DeleteAt, time.Now())
+	if err != nil {
+		response.Validation(c, err.Error())
 		return
 	}
 
-	var deleteAt *time.Time
-	if body.DeleteAt != 0 {
-		tmp := time.Unix(body.DeleteAt, 0)
-		deleteAt = &tmp
+	update := &model.UserSegmentsUpdate{
+		UserID:     body.UserID,
+		SlugsToAdd: slugsToAdd,
+		SlugsToDel: body.SlugsToDel,
+		Strict:     body.Strict,
 	}
+	results, err := h.repo.UpdateUserSegments(c.Request.Context(), update)
+	if err != nil {
+		if database.IsUpdateUserSegmentsUnknownSegmentsErr(err) {
+			response.ErrorWith(c, database.NewError(database.CodeValidation, "unknown segments"), gin.H{"results": results})
+			return
+		}
 
-	if err := h.repo.UpdateUserSegments(c.Request.Context(), body.UserID, body.SlugsToAdd, body.SlugsToDel, deleteAt); err != nil {
-		if database.IsUpdateUserSegmentsInvalidSegmentsErr(err) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid segments"})
+		response.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": body.UserID, "results": results})
+}
+
+// @Summary Update Users Segments
+// @Tags users
+// @Description Apply per-user segments updates in batch and report the outcome per user,
+// @Description strict update of a user with unknown slugs is rejected without affecting the others
+// @Accept json
+// @Produce json
+// @Param body body UpdateUsersSegmentsRequest true "per-user updates"
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /users/segments [put]
+func (h *Handler) updateUsersSegments(c *gin.Context) {
+	var body UpdateUsersSegmentsRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	for _, u := range body.Updates {
+		recorder.Users(c, u.UserID)
+		recorder.Segments(c, u.SlugsToDel...)
+		for _, s := range u.SlugsToAdd {
+			recorder.Segments(c, s.Slug)
+		}
+	}
+
+	now := time.Now()
+	updates := make([]*model.UserSegmentsUpdate, 0, len(body.Updates))
+	for i, u := range body.Updates {
+		slugsToAdd, err := parseSlugsToAdd(u.SlugsToAdd, u.ActiveFrom, u.DeleteAt, now)
+		if err != nil {
+			response.Validation(c, fmt.Sprintf("updates[%d]: %s", i, err))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		updates = append(updates, &model.UserSegmentsUpdate{
+			UserID:     u.UserID,
+			SlugsToAdd: slugsToAdd,
+			SlugsToDel: u.SlugsToDel,
+			Strict:     u.Strict,
+		})
+	}
+
+	results, err := h.repo.UpdateUsersSegments(c.Request.Context(), updates)
+	if err != nil {
+		response.ErrorWith(c, err, gin.H{"results": results})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// usersSegmentsAction dispatches custom methods of users segments collection,
+// gin can't route a literal colon, so the method comes as the action param
+func (h *Handler) usersSegmentsAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":lookup":
+		h.lookupUsersSegments(c)
+	default:
+		response.NotFound(c, "page not found")
+	}
+}
+
+// @Summary Lookup Users Segments
+// @Tags users
+// @Description Get active segments of many users at once, users without segments and unknown users get empty lists
+// @Accept json
+// @Produce json
+// @Param body body LookupUsersSegmentsRequest true "users ids"
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /users/segments:lookup [post]
+func (h *Handler) lookupUsersSegments(c *gin.Context) {
+	var body LookupUsersSegmentsRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	segments, err := h.repo.LookupUsersSegments(c.Request.Context(), body.UsersIDs)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": segments})
+}
+
+// @Summary Upload Segment Users
+// @Tags user
+// @Description Add or remove users listed in uploaded CSV or newline-delimited file (user id in the first column) to segment
+// @Accept multipart/form-data
+// @Produce json
+// @Param slug formData string true "segment slug"
+// @Param operation formData string false "operation" Enums(add, remove) default(add)
+// @Param delete_at formData int false "TTL as unix time for added users"
+// @Param ttl_seconds formData int false "TTL in seconds for added users, at most 10 years"
+// @Param file formData file true "users ids"
+// @Success 200
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/segment/upload [post]
+func (h *Handler) uploadSegmentUsers(c *gin.Context) {
+	var body UploadSegmentUsersRequest
+	if err := c.ShouldBind(&body); err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	recorder.Segments(c, body.Slug)
+
+	deleteAt, err := parseDeleteAt(body.DeleteAt, body.TTLSeconds, time.Now())
+	if err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	usersIDs, invalid, duplicates, err := parseUsersIDs(file)
+	if err != nil {
+		response.Validation(c, err.Error())
+		return
+	}
+	if len(usersIDs) == 0 {
+		response.ErrorWith(c, database.NewError(database.CodeValidation, "no valid users ids in file"), gin.H{"invalid": invalid})
+		return
+	}
+	recorder.Users(c, usersIDs...)
+
+	var result *model.BulkResult
+	if body.Operation == model.OperationRemove {
+		result, err = h.repo.RemoveSegmentUsers(c.Request.Context(), body.Slug, usersIDs)
+	} else {
+		result, err = h.repo.AddSegmentUsers(c.Request.Context(), body.Slug, usersIDs, deleteAt)
+	}
+	if err != nil {
+		if database.IsRecordNotFoundError(err) {
+			response.NotFound(c, fmt.Sprintf("segment %s not found", body.Slug))
+			return
+		}
+
+		// chunks committed before the failure stay applied
+		response.ErrorWith(c, err, gin.H{"result": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"slug":      body.Slug,
+		"operation": body.Operation,
+		"added":     result.Added,
+		"removed":   result.Removed,
+		"skipped":   result.Skipped + duplicates,
+		"invalid":   invalid,
+	})
+}
+
+// parseSlugsToAdd resolves start and TTL of every slug, slugs without them fall back to the defaults
+func parseSlugsToAdd(slugs []SlugToAdd, defaultActiveFrom, defaultDeleteAt int64, now time.Time) ([]*model.SegmentToAdd, error) {
+	slugsToAdd := make([]*model.SegmentToAdd, 0, len(slugs))
+	for _, s := range slugs {
+		if s.Slug == "" {
+			return nil, errors.New("empty slug in slugs_to_add")
+		}
+		if s.ActiveFrom == 0 {
+			s.ActiveFrom = defaultActiveFrom
+		}
+		if s.DeleteAt == 0 && s.TTLSeconds == 0 {
+			s.DeleteAt = defaultDeleteAt
+		}
+
+		activeFrom, err := parseActiveFrom(s.ActiveFrom, now)
+		if err != nil {
+			return nil, fmt.Errorf("slug %s: %w", s.Slug, err)
+		}
+		// TTL counts from the scheduled start
+		from := now
+		if activeFrom != nil {
+			from = *activeFrom
+		}
+		deleteAt, err := parseDeleteAt(s.DeleteAt, s.TTLSeconds, from)
+		if err != nil {
+			return nil, fmt.Errorf("slug %s: %w", s.Slug, err)
+		}
+
+		slugsToAdd = append(slugsToAdd, &model.SegmentToAdd{Slug: s.Slug, ActiveFrom: activeFrom, DeleteAt: deleteAt})
+	}
+
+	return slugsToAdd, nil
+}
+
+// parseActiveFrom resolves scheduled start given as unix time, nil means the membership starts immediately
+func parseActiveFrom(activeFrom int64, now time.Time) (*time.Time, error) {
+	if activeFrom == 0 {
+		return nil, nil
+	}
+	if activeFrom < now.Unix() {
+		return nil, errors.New("invalid value active_from")
+	}
+	if activeFrom > now.Unix()+sModel.MaxTTLSeconds {
+		return nil, fmt.Errorf("active_from must be within %d seconds from now", sModel.MaxTTLSeconds)
+	}
+
+	tmp := time.Unix(activeFrom, 0)
+	return &tmp, nil
+}
+
+// parseDeleteAt resolves TTL given either as unix time or as seconds from the start, nil means no TTL.
+// Unix times are bounded like TTL, so they fit postgres timestamps.
+func parseDeleteAt(deleteAt, ttlSeconds int64, from time.Time) (*time.Time, error) {
+	if deleteAt != 0 && ttlSeconds != 0 {
+		return nil, errors.New("only one of delete_at and ttl_seconds can be set")
+	}
+	if deleteAt != 0 && deleteAt <= from.Unix() {
+		return nil, errors.New("invalid value delete_at")
+	}
+	if deleteAt > from.Unix()+sModel.MaxTTLSeconds {
+		return nil, fmt.Errorf("delete_at must be within %d seconds from the start", sModel.MaxTTLSeconds)
+	}
+	if ttlSeconds < 0 || ttlSeconds > sModel.MaxTTLSeconds {
+		return nil, fmt.Errorf("ttl_seconds must be within 0 and %d", sModel.MaxTTLSeconds)
+	}
+
+	switch {
+	case deleteAt != 0:
+		tmp := time.Unix(deleteAt, 0)
+		return &tmp, nil
+	case ttlSeconds != 0:
+		tmp := from.Add(time.Duration(ttlSeconds) * time.Second)
+		if !tmp.After(from) {
+			return nil, errors.New("invalid value ttl_seconds")
+		}
+		return &tmp, nil
+	}
+
+	return nil, nil
+}
+
+// parseUsersIDs reads users ids from the first column of CSV or newline-delimited file.
+// Header line and empty lines are ignored, duplicated ids are counted and dropped.
+func parseUsersIDs(r io.Reader) (usersIDs []uint, invalid uint, duplicates uint, err error) {
+	seen := make(map[uint]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+		field, _, _ := strings.Cut(scanner.Text(), ",")
+		field, _, _ = strings.Cut(field, ";")
+		field = strings.Trim(strings.TrimSpace(field), `"`)
+		if field == "" {
+			continue
+		}
+
+		id, err := strconv.ParseUint(field, 10, 31)
+		if err != nil || id == 0 {
+			if line == 0 && err != nil {
+				continue
+			}
+			invalid++
+			continue
+		}
+
+		if _, ok := seen[uint(id)]; ok {
+			duplicates++
+			continue
+		}
+		seen[uint(id)] = struct{}{}
+		usersIDs = append(usersIDs, uint(id))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+
+	return usersIDs, invalid, duplicates, nil
 }
 
 func NewHandler(repo repo.Repo) *Handler {
@@ -134,12 +573,24 @@ func NewHandler(repo repo.Repo) *Handler {
 	}
 }
 
-func Route(r *gin.Engine, h *Handler) {
+func Route(r *gin.Engine, h *Handler, guard auth.Guard, audit recorder.Recorder) {
 	router := r.Group("user")
 
 	{
-		router.GET("/:user_id", h.getUserSegments)
-		router.GET("/history/:user_id", h.getUserHistory)
-		router.PUT("/segment", h.updateUserSegments)
+		router.POST("", audit.Record(aModel.ActionUserCreate), guard.Require(auth.RoleEditor), h.createUser)
+		router.GET("/:user_id", guard.Require(auth.RoleReader), h.getUserSegments)
+		router.GET("/:user_id/profile", guard.Require(auth.RoleReader), h.getUserProfile)
+		router.DELETE("/:user_id", audit.Record(aModel.ActionUserErase), guard.Require(auth.RoleAdmin), h.deleteUser)
+		router.GET("/history/:user_id", guard.Require(auth.RoleReader), h.getUserHistory)
+		router.PUT("/segment", audit.Record(aModel.ActionUserSegmentsUpdate), guard.Require(auth.RoleEditor), h.updateUserSegments)
+		router.POST("/segment/upload", audit.Record(aModel.ActionUserSegmentsUpload), guard.Require(auth.RoleEditor), h.uploadSegmentUsers)
+	}
+
+	usersRouter := r.Group("users")
+
+	{
+		usersRouter.PUT("/segments", audit.Record(aModel.ActionUsersSegmentsUpdate), guard.Require(auth.RoleEditor), h.updateUsersSegments)
+		// the only action is lookup, which only reads
+		usersRouter.POST("/segments:action", guard.Require(auth.RoleReader), h.usersSegmentsAction)
 	}
 }
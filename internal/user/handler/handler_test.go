@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,7 +17,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
+	"avito_2023/internal/audit/recorder"
+	"avito_2023/internal/auth"
 	"avito_2023/internal/database"
+	"avito_2023/internal/segment/slug"
 	"avito_2023/internal/user/handler"
 	"avito_2023/internal/user/model"
 	"avito_2023/internal/user/repo/mocks"
@@ -32,10 +38,14 @@ func (s *Suite) SetupSuite() {
 	s.repo = &mocks.RepoMock{}
 	s.handler = handler.NewHandler(s.repo)
 
+	slugs, err := slug.NewPolicy(slug.DefaultOptions())
+	s.Require().NoError(err)
+	s.Require().NoError(slugs.RegisterValidation())
+
 	gin.SetMode(gin.TestMode)
 	s.r = gin.Default()
 
-	handler.Route(s.r, s.handler)
+	handler.Route(s.r, s.handler, auth.Disabled{}, recorder.Disabled{})
 }
 
 func TestSuite(t *testing.T) {
@@ -70,7 +80,21 @@ func (s *Suite) TestGetUserSegments() {
 			`,
 		},
 		{
-			name:        "segments not found",
+			name:        "user without segments",
+			inputUserID: 1000,
+			mockFc: func(ctx context.Context, userID uint) ([]*string, error) {
+				return []*string{}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "user_id":  1000,
+				  "segments": []
+				}
+			`,
+		},
+		{
+			name:        "user not found",
 			inputUserID: 1000,
 			mockFc: func(ctx context.Context, userID uint) ([]*string, error) {
 				return nil, database.ErrNotFound
@@ -78,7 +102,7 @@ func (s *Suite) TestGetUserSegments() {
 			expectedCode: http.StatusNotFound,
 			expectedResp: `
 				{
-				  "error": "segments for user 1000 not found"
+				  "error": {"code": "not_found", "message": "user 1000 not found"}
 				}
 			`,
 		},
@@ -91,7 +115,7 @@ func (s *Suite) TestGetUserSegments() {
 			expectedCode: http.StatusInternalServerError,
 			expectedResp: `
 				{
-				  "error": "something went wrong"
+				  "error": {"code": "internal_error", "message": "internal error"}
 				}
 			`,
 		},
@@ -116,8 +140,227 @@ func (s *Suite) TestGetUserSegments() {
 	}
 }
 
+func (s *Suite) TestCreateUser() {
+	createdAt := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		inputBody    map[string]interface{}
+		mockFc       func(ctx context.Context, userID uint) (*model.UserDB, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name: "create user",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+			},
+			mockFc: func(ctx context.Context, userID uint) (*model.UserDB, error) {
+				return &model.UserDB{ID: userID, CreatedAt: createdAt}, nil
+			},
+			expectedCode: http.StatusCreated,
+			expectedResp: `
+				{
+				  "user_id": 1000,
+				  "created_at": "2023-08-15T12:30:00Z"
+				}
+			`,
+		},
+		{
+			name: "invalid request body",
+			inputBody: map[string]interface{}{
+				"wrong": "wrong",
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "user already exists",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+			},
+			mockFc: func(ctx context.Context, userID uint) (*model.UserDB, error) {
+				return nil, database.ErrCreateUser_AlreadyExists
+			},
+			expectedCode: http.StatusConflict,
+			expectedResp: `
+				{
+				  "error": {"code": "already_exists", "message": "user 1000 already exists"}
+				}
+			`,
+		},
+		{
+			name: "failed to create user in db",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+			},
+			mockFc: func(ctx context.Context, userID uint) (*model.UserDB, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.CreateUserFunc = tc.mockFc
+			}
+
+			b, _ := json.Marshal(tc.inputBody)
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/user", bytes.NewBuffer(b))
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestGetUserProfile() {
+	createdAt := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
+	changedAt := createdAt.Add(time.Hour)
+
+	testCases := []struct {
+		name         string
+		inputUserID  uint
+		mockFc       func(ctx context.Context, userID uint) (*model.UserProfile, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:        "get user profile",
+			inputUserID: 1000,
+			mockFc: func(ctx context.Context, userID uint) (*model.UserProfile, error) {
+				return &model.UserProfile{
+					UserID:            userID,
+					CreatedAt:         createdAt,
+					ActiveSegments:    []string{"test-slug-1"},
+					ScheduledSegments: []string{},
+					LastChangeAt:      &changedAt,
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "user_id": 1000,
+				  "created_at": "2023-08-15T12:30:00Z",
+				  "active_segments": ["test-slug-1"],
+				  "scheduled_segments": [],
+				  "last_change_at": "2023-08-15T13:30:00Z"
+				}
+			`,
+		},
+		{
+			name:        "user not found",
+			inputUserID: 1000,
+			mockFc: func(ctx context.Context, userID uint) (*model.UserProfile, error) {
+				return nil, database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedResp: `
+				{
+				  "error": {"code": "not_found", "message": "user 1000 not found"}
+				}
+			`,
+		},
+		{
+			name:        "failed to get user profile",
+			inputUserID: 1000,
+			mockFc: func(ctx context.Context, userID uint) (*model.UserProfile, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.GetUserProfileFunc = tc.mockFc
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/user/%d/profile", tc.inputUserID), nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestDeleteUser() {
+	testCases := []struct {
+		name         string
+		inputUserID  string
+		mockFc       func(ctx context.Context, userID uint) error
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:        "delete user",
+			inputUserID: "1000",
+			mockFc: func(ctx context.Context, userID uint) error {
+				return nil
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "invalid user id",
+			inputUserID:  "wrong",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:        "user not found",
+			inputUserID: "1000",
+			mockFc: func(ctx context.Context, userID uint) error {
+				return database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedResp: `
+				{
+				  "error": {"code": "not_found", "message": "user 1000 not found"}
+				}
+			`,
+		},
+		{
+			name:        "failed to delete user",
+			inputUserID: "1000",
+			mockFc: func(ctx context.Context, userID uint) error {
+				return fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.DeleteUserFunc = tc.mockFc
+			}
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/user/"+tc.inputUserID, nil)
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
 func (s *Suite) TestGetUserHistory() {
-	now := time.Now()
+	now := time.Date(2023, time.August, 15, 12, 30, 0, 0, time.UTC)
 
 	testCases := []struct {
 		name         string
@@ -131,69 +374,75 @@ func (s *Suite) TestGetUserHistory() {
 		{
 			name:        "get user history",
 			inputUserID: 1000,
-			inputMonth:  time.Now().Month(),
-			inputYear:   time.Now().Year(),
+			inputMonth:  now.Month(),
+			inputYear:   now.Year(),
 			mockFc: func(ctx context.Context, userID uint, month uint, year uint) ([]*model.UserHistory, error) {
-				userHistory := []model.UserHistory{
+				return []*model.UserHistory{
 					{
 						Slug:      "test-slug-1",
+						Operation: model.OperationAdd,
+						Source:    model.SourceManual,
 						CreatedAt: now,
 					},
 					{
 						Slug:      "test-slug-2",
-						CreatedAt: now.AddDate(0, -2, 0),
-						DeletedAt: &now,
+						Operation: model.OperationAdd,
+						Source:    model.SourcePercentage,
+						CreatedAt: now.Add(time.Hour),
 					},
 					{
-						Slug:      "test-slug-3",
-						CreatedAt: now.AddDate(-1, 0, 0),
-						DeletedAt: &now,
+						Slug:      "test-slug-1",
+						Operation: model.OperationRemove,
+						Source:    model.SourceManual,
+						CreatedAt: now.Add(2 * time.Hour),
 					},
-				}
-				history := make([]*model.UserHistory, len(userHistory))
-				for i := 0; i < len(userHistory); i++ {
-					history[i] = &userHistory[i]
-				}
-				return history, nil
+				}, nil
 			},
 			expectedCode: http.StatusOK,
-			expectedResp: fmt.Sprintf(`
+			expectedResp: `
 				{
 				  "user_id": 1000,
 				  "history": [
 				  	{
 					  "slug": "test-slug-1",
-					  "created_at": "%s",
-					  "deleted_at": null
+					  "operation": "add",
+					  "source": "manual",
+					  "created_at": "2023-08-15T12:30:00Z"
 					},
 				  	{
 					  "slug": "test-slug-2",
-					  "created_at": "%s",
-					  "deleted_at": "%s"
+					  "operation": "add",
+					  "source": "percentage",
+					  "created_at": "2023-08-15T13:30:00Z"
 					},
 					{
-					  "slug": "test-slug-3",
-					  "created_at": "%s",
-					  "deleted_at": "%s"
+					  "slug": "test-slug-1",
+					  "operation": "remove",
+					  "source": "manual",
+					  "created_at": "2023-08-15T14:30:00Z"
 					}
 				  ]
 				}
-			`, now.Format("2006-01-02T15:04:05.999999Z07:00"),
-				now.AddDate(0, -2, 0).Format("2006-01-02T15:04:05.999999Z07:00"),
-				now.Format("2006-01-02T15:04:05.999999Z07:00"),
-				now.AddDate(-1, 0, 0).Format("2006-01-02T15:04:05.999999Z07:00"),
-				now.Format("2006-01-02T15:04:05.999999Z07:00")),
+			`,
 		},
 		{
 			name:         "invalid request uri (user_id)",
 			expectedCode: http.StatusBadRequest,
-			expectedResp: "{\"error\":\"Key: 'GetUserHistoryUri.UserID' Error:Field validation for 'UserID' failed on the 'required' tag\"}",
+			expectedResp: "{\"error\":{\"code\":\"validation_failed\",\"message\":\"Key: 'GetUserHistoryUri.UserID' Error:Field validation for 'UserID' failed on the 'required' tag\"}}",
 		},
 		{
 			name:         "invalid request query (month)",
 			inputUserID:  1000,
 			expectedCode: http.StatusBadRequest,
-			expectedResp: "{\"error\":\"Key: 'GetUserHistoryQuery.Month' Error:Field validation for 'Month' failed on the 'required' tag\\nKey: 'GetUserHistoryQuery.Year' Error:Field validation for 'Year' failed on the 'required' tag\"}",
+			expectedResp: "{\"error\":{\"code\":\"validation_failed\",\"message\":\"Key: 'GetUserHistoryQuery.Month' Error:Field validation for 'Month' failed on the 'required' tag\\nKey: 'GetUserHistoryQuery.Year' Error:Field validation for 'Year' failed on the 'required' tag\"}}",
+		},
+		{
+			name:         "invalid request query (month out of range)",
+			inputUserID:  1000,
+			inputMonth:   13,
+			inputYear:    2023,
+			expectedCode: http.StatusBadRequest,
+			expectedResp: "{\"error\":{\"code\":\"validation_failed\",\"message\":\"Key: 'GetUserHistoryQuery.Month' Error:Field validation for 'Month' failed on the 'max' tag\"}}",
 		},
 		{
 			name:        "history not found",
@@ -206,7 +455,7 @@ func (s *Suite) TestGetUserHistory() {
 			expectedCode: http.StatusNotFound,
 			expectedResp: `
 				{
-				  "error": "history for user 1000 not found"
+				  "error": {"code": "not_found", "message": "history for user 1000 not found"}
 				}
 			`,
 		},
@@ -221,7 +470,7 @@ func (s *Suite) TestGetUserHistory() {
 			expectedCode: http.StatusInternalServerError,
 			expectedResp: `
 				{
-				  "error": "something went wrong"
+				  "error": {"code": "internal_error", "message": "internal error"}
 				}
 			`,
 		},
@@ -251,7 +500,7 @@ func (s *Suite) TestUpdateUserSegments() {
 	testCases := []struct {
 		name         string
 		inputBody    map[string]interface{}
-		mockFc       func(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error
+		mockFc       func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error)
 		expectedCode int
 		expectedResp string
 	}{
@@ -262,10 +511,29 @@ func (s *Suite) TestUpdateUserSegments() {
 				"slugs_to_add": []string{"test-slug-1", "test-slug-2", "test-slug-3"},
 				"slugs_to_del": []string{"test-slug-4"},
 			},
-			mockFc: func(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error {
-				return nil
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				if update.UserID != 1000 || len(update.SlugsToAdd) != 3 || len(update.SlugsToDel) != 1 || update.Strict {
+					return nil, fmt.Errorf("unexpected update")
+				}
+				return []*model.SlugResult{
+					{Slug: "test-slug-1", Operation: model.OperationAdd, Status: model.SlugStatusAdded},
+					{Slug: "test-slug-2", Operation: model.OperationAdd, Status: model.SlugStatusAlreadyMember},
+					{Slug: "test-slug-3", Operation: model.OperationAdd, Status: model.SlugStatusUnknownSegment},
+					{Slug: "test-slug-4", Operation: model.OperationRemove, Status: model.SlugStatusNotMember},
+				}, nil
 			},
-			expectedCode: http.StatusNoContent,
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "user_id": 1000,
+				  "results": [
+				    {"slug": "test-slug-1", "operation": "add", "status": "added"},
+				    {"slug": "test-slug-2", "operation": "add", "status": "already_member"},
+				    {"slug": "test-slug-3", "operation": "add", "status": "unknown_segment"},
+				    {"slug": "test-slug-4", "operation": "remove", "status": "not_member"}
+				  ]
+				}
+			`,
 		},
 		{
 			name: "update user segments",
@@ -275,10 +543,206 @@ func (s *Suite) TestUpdateUserSegments() {
 				"slugs_to_del": []string{"test-slug-4"},
 				"delete_at":    time.Now().AddDate(0, 0, 2).Unix(),
 			},
-			mockFc: func(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error {
-				return nil
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				return nil, nil
 			},
-			expectedCode: http.StatusNoContent,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "update user segments with per-slug TTL",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					"test-slug-1",
+					map[string]interface{}{"slug": "test-slug-2", "ttl_seconds": 172800},
+					map[string]interface{}{"slug": "test-slug-3", "delete_at": time.Now().AddDate(0, 1, 0).Unix()},
+				},
+				"slugs_to_del": []string{},
+			},
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				if len(update.SlugsToAdd) != 3 || update.SlugsToAdd[0].Slug != "test-slug-1" || update.SlugsToAdd[0].DeleteAt != nil {
+					return nil, fmt.Errorf("unexpected slugs to add")
+				}
+				if update.SlugsToAdd[1].DeleteAt == nil || time.Until(*update.SlugsToAdd[1].DeleteAt) > 48*time.Hour {
+					return nil, fmt.Errorf("unexpected ttl for %s", update.SlugsToAdd[1].Slug)
+				}
+				if update.SlugsToAdd[2].DeleteAt == nil || time.Until(*update.SlugsToAdd[2].DeleteAt) < 27*24*time.Hour {
+					return nil, fmt.Errorf("unexpected ttl for %s", update.SlugsToAdd[2].Slug)
+				}
+				return nil, nil
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "update user segments with default delete_at",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					"test-slug-1",
+					map[string]interface{}{"slug": "test-slug-2", "ttl_seconds": 60},
+				},
+				"slugs_to_del": []string{},
+				"delete_at":    time.Now().AddDate(0, 0, 2).Unix(),
+			},
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				if update.SlugsToAdd[0].DeleteAt == nil || time.Until(*update.SlugsToAdd[0].DeleteAt) < 24*time.Hour {
+					return nil, fmt.Errorf("default delete_at is not applied")
+				}
+				if update.SlugsToAdd[1].DeleteAt == nil || time.Until(*update.SlugsToAdd[1].DeleteAt) > time.Minute {
+					return nil, fmt.Errorf("per-slug ttl is not applied")
+				}
+				return nil, nil
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "update user segments with scheduled activation",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					"test-slug-1",
+					map[string]interface{}{"slug": "test-slug-2", "active_from": time.Now().AddDate(0, 0, 3).Unix(), "ttl_seconds": 172800},
+				},
+				"slugs_to_del": []string{},
+			},
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				if update.SlugsToAdd[0].ActiveFrom != nil {
+					return nil, fmt.Errorf("unexpected active_from for %s", update.SlugsToAdd[0].Slug)
+				}
+				s := update.SlugsToAdd[1]
+				if s.ActiveFrom == nil || s.DeleteAt == nil || s.DeleteAt.Sub(*s.ActiveFrom) != 48*time.Hour {
+					return nil, fmt.Errorf("ttl is not counted from active_from for %s", s.Slug)
+				}
+				return nil, nil
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "invalid request body (active_from)",
+			inputBody: map[string]interface{}{
+				"user_id":      1000,
+				"slugs_to_add": []string{"test-slug-1"},
+				"slugs_to_del": []string{},
+				"active_from":  time.Now().AddDate(0, 0, -1).Unix(),
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "slug test-slug-1: invalid value active_from"}
+				}
+			`,
+		},
+		{
+			name: "invalid request body (active_from overflow)",
+			inputBody: map[string]interface{}{
+				"user_id":      1000,
+				"slugs_to_add": []string{"test-slug-1"},
+				"slugs_to_del": []string{},
+				"active_from":  int64(math.MaxInt64),
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "slug test-slug-1: active_from must be within 315360000 seconds from now"}
+				}
+			`,
+		},
+		{
+			name: "invalid request body (delete_at overflow for slug)",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					map[string]interface{}{"slug": "test-slug-1", "delete_at": int64(math.MaxInt64)},
+				},
+				"slugs_to_del": []string{},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "slug test-slug-1: delete_at must be within 315360000 seconds from the start"}
+				}
+			`,
+		},
+		{
+			name: "invalid request body (delete_at before active_from)",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					map[string]interface{}{
+						"slug":        "test-slug-1",
+						"active_from": time.Now().AddDate(0, 0, 3).Unix(),
+						"delete_at":   time.Now().AddDate(0, 0, 2).Unix(),
+					},
+				},
+				"slugs_to_del": []string{},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "slug test-slug-1: invalid value delete_at"}
+				}
+			`,
+		},
+		{
+			name: "invalid request body (ttl_seconds overflow for slug)",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					map[string]interface{}{"slug": "test-slug-1", "ttl_seconds": 10000000000},
+				},
+				"slugs_to_del": []string{},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "slug test-slug-1: ttl_seconds must be within 0 and 315360000"}
+				}
+			`,
+		},
+		{
+			name: "invalid request body (both delete_at and ttl_seconds for slug)",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					map[string]interface{}{"slug": "test-slug-1", "ttl_seconds": 60, "delete_at": time.Now().AddDate(0, 0, 2).Unix()},
+				},
+				"slugs_to_del": []string{},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "slug test-slug-1: only one of delete_at and ttl_seconds can be set"}
+				}
+			`,
+		},
+		{
+			name: "invalid request body (empty slug)",
+			inputBody: map[string]interface{}{
+				"user_id": 1000,
+				"slugs_to_add": []interface{}{
+					map[string]interface{}{"ttl_seconds": 60},
+				},
+				"slugs_to_del": []string{},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid request body (slug too long)",
+			inputBody: map[string]interface{}{
+				"user_id":      1000,
+				"slugs_to_add": []string{"test-slug-1"},
+				"slugs_to_del": []string{strings.Repeat("A", 51)},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid request body (slugs_to_add item)",
+			inputBody: map[string]interface{}{
+				"user_id":      1000,
+				"slugs_to_add": []interface{}{42},
+				"slugs_to_del": []string{},
+			},
+			expectedCode: http.StatusBadRequest,
 		},
 		{
 			name: "invalid request body",
@@ -311,25 +775,36 @@ func (s *Suite) TestUpdateUserSegments() {
 				"slugs_to_del": []string{"test-slug-4"},
 				"delete_at":    time.Now().AddDate(0, 0, -2).Unix(),
 			},
-			mockFc: func(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error {
-				return nil
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				return nil, nil
 			},
 			expectedCode: http.StatusBadRequest,
 		},
 		{
-			name: "invalid segments",
+			name: "unknown segments in strict mode",
 			inputBody: map[string]interface{}{
 				"user_id":      1000,
-				"slugs_to_add": []string{"wrong-slug-1", "wrong-slug-2", "wrong-slug-3"},
-				"slugs_to_del": []string{"test-slug-1"},
+				"slugs_to_add": []string{"wrong-slug-1", "test-slug-2"},
+				"slugs_to_del": []string{"wrong-slug-3"},
+				"strict":       true,
 			},
-			mockFc: func(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error {
-				return database.ErrUpdateUserSegments_InvalidSegments
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				if !update.Strict {
+					return nil, fmt.Errorf("unexpected update")
+				}
+				return []*model.SlugResult{
+					{Slug: "wrong-slug-1", Operation: model.OperationAdd, Status: model.SlugStatusUnknownSegment},
+					{Slug: "wrong-slug-3", Operation: model.OperationRemove, Status: model.SlugStatusUnknownSegment},
+				}, database.ErrUpdateUserSegments_UnknownSegments
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedResp: `
 				{
-				  "error": "invalid segments"
+				  "error": {"code": "validation_failed", "message": "unknown segments"},
+				  "results": [
+				    {"slug": "wrong-slug-1", "operation": "add", "status": "unknown_segment"},
+				    {"slug": "wrong-slug-3", "operation": "remove", "status": "unknown_segment"}
+				  ]
 				}
 			`,
 		},
@@ -340,13 +815,13 @@ func (s *Suite) TestUpdateUserSegments() {
 				"slugs_to_add": []string{"test-slug-1", "test-slug-2", "test-slug-3"},
 				"slugs_to_del": []string{"test-slug-4"},
 			},
-			mockFc: func(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error {
-				return fmt.Errorf("something went wrong")
+			mockFc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
+				return nil, fmt.Errorf("something went wrong")
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedResp: `
 				{
-				  "error": "something went wrong"
+				  "error": {"code": "internal_error", "message": "internal error"}
 				}
 			`,
 		},
@@ -373,3 +848,348 @@ func (s *Suite) TestUpdateUserSegments() {
 		})
 	}
 }
+
+func (s *Suite) TestUpdateUsersSegments() {
+	testCases := []struct {
+		name         string
+		inputBody    map[string]interface{}
+		mockFc       func(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name: "update users segments",
+			inputBody: map[string]interface{}{
+				"updates": []map[string]interface{}{
+					{"user_id": 1000, "slugs_to_add": []string{"test-slug-1"}, "slugs_to_del": []string{}},
+					{"user_id": 1002, "slugs_to_add": []string{"wrong-slug"}, "slugs_to_del": []string{}, "strict": true},
+					{"user_id": 1004, "slugs_to_add": []interface{}{}, "slugs_to_del": []string{"test-slug-1"}},
+				},
+			},
+			mockFc: func(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error) {
+				if len(updates) != 3 || updates[0].UserID != 1000 || !updates[1].Strict || updates[2].SlugsToDel[0] != "test-slug-1" {
+					return nil, fmt.Errorf("unexpected updates")
+				}
+				return []*model.UserSegmentsResult{
+					{UserID: 1000, Results: []*model.SlugResult{{Slug: "test-slug-1", Operation: model.OperationAdd, Status: model.SlugStatusAdded}}},
+					{UserID: 1002, Results: []*model.SlugResult{{Slug: "wrong-slug", Operation: model.OperationAdd, Status: model.SlugStatusUnknownSegment}}, Error: "unknown segments"},
+					{UserID: 1004, Results: []*model.SlugResult{{Slug: "test-slug-1", Operation: model.OperationRemove, Status: model.SlugStatusNotMember}}},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "results": [
+				    {"user_id": 1000, "results": [{"slug": "test-slug-1", "operation": "add", "status": "added"}]},
+				    {"user_id": 1002, "results": [{"slug": "wrong-slug", "operation": "add", "status": "unknown_segment"}], "error": "unknown segments"},
+				    {"user_id": 1004, "results": [{"slug": "test-slug-1", "operation": "remove", "status": "not_member"}]}
+				  ]
+				}
+			`,
+		},
+		{
+			name: "invalid request body (empty updates)",
+			inputBody: map[string]interface{}{
+				"updates": []map[string]interface{}{},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid request body (user update)",
+			inputBody: map[string]interface{}{
+				"updates": []map[string]interface{}{
+					{"user_id": 1000, "slugs_to_add": []string{"test-slug-1"}, "slugs_to_del": []string{}},
+					{"slugs_to_add": []string{"test-slug-1"}, "slugs_to_del": []string{}},
+				},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid request body (ttl)",
+			inputBody: map[string]interface{}{
+				"updates": []map[string]interface{}{
+					{"user_id": 1000, "slugs_to_add": []string{"test-slug-1"}, "slugs_to_del": []string{}},
+					{"user_id": 1002, "slugs_to_add": []string{"test-slug-1"}, "slugs_to_del": []string{}, "delete_at": time.Now().AddDate(0, 0, -1).Unix()},
+				},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `
+				{
+				  "error": {"code": "validation_failed", "message": "updates[1]: slug test-slug-1: invalid value delete_at"}
+				}
+			`,
+		},
+		{
+			name: "failed to update users segments in db",
+			inputBody: map[string]interface{}{
+				"updates": []map[string]interface{}{
+					{"user_id": 1000, "slugs_to_add": []string{"test-slug-1"}, "slugs_to_del": []string{}},
+				},
+			},
+			mockFc: func(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedResp: `
+				{
+				  "error": {"code": "internal_error", "message": "internal error"},
+				  "results": null
+				}
+			`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.UpdateUsersSegmentsFunc = tc.mockFc
+			}
+
+			b, _ := json.Marshal(tc.inputBody)
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/users/segments", bytes.NewBuffer(b))
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestLookupUsersSegments() {
+	tooManyIDs := make([]uint, 5001)
+	for i := range tooManyIDs {
+		tooManyIDs[i] = uint(i + 1)
+	}
+
+	testCases := []struct {
+		name         string
+		inputPath    string
+		inputBody    map[string]interface{}
+		mockFc       func(ctx context.Context, usersIDs []uint) (map[uint][]string, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:      "lookup users segments",
+			inputPath: "/users/segments:lookup",
+			inputBody: map[string]interface{}{
+				"user_ids": []uint{1000, 1002},
+			},
+			mockFc: func(ctx context.Context, usersIDs []uint) (map[uint][]string, error) {
+				return map[uint][]string{
+					1000: {"test-slug-1", "test-slug-2"},
+					1002: {},
+				}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `
+				{
+				  "users": {
+				    "1000": ["test-slug-1", "test-slug-2"],
+				    "1002": []
+				  }
+				}
+			`,
+		},
+		{
+			name:      "unknown method",
+			inputPath: "/users/segments:wrong",
+			inputBody: map[string]interface{}{
+				"user_ids": []uint{1000},
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:      "invalid request body (empty user_ids)",
+			inputPath: "/users/segments:lookup",
+			inputBody: map[string]interface{}{
+				"user_ids": []uint{},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:      "invalid request body (zero user id)",
+			inputPath: "/users/segments:lookup",
+			inputBody: map[string]interface{}{
+				"user_ids": []uint{1000, 0},
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:      "invalid request body (too many user_ids)",
+			inputPath: "/users/segments:lookup",
+			inputBody: map[string]interface{}{
+				"user_ids": tooManyIDs,
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:      "failed to lookup users segments in db",
+			inputPath: "/users/segments:lookup",
+			inputBody: map[string]interface{}{
+				"user_ids": []uint{1000},
+			},
+			mockFc: func(ctx context.Context, usersIDs []uint) (map[uint][]string, error) {
+				return nil, fmt.Errorf("something went wrong")
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedResp: `
+				{
+				  "error": {"code": "internal_error", "message": "internal error"}
+				}
+			`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.mockFc != nil {
+				s.repo.LookupUsersSegmentsFunc = tc.mockFc
+			}
+
+			b, _ := json.Marshal(tc.inputBody)
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, tc.inputPath, bytes.NewBuffer(b))
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
+
+func (s *Suite) TestUploadSegmentUsers() {
+	testCases := []struct {
+		name         string
+		inputFields  map[string]string
+		inputFile    string
+		addMockFc    func(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error)
+		removeMockFc func(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error)
+		expectedCode int
+		expectedResp string
+	}{
+		{
+			name:        "add users from csv",
+			inputFields: map[string]string{"slug": "test-slug"},
+			inputFile:   "user_id;comment\n1000;first\n1002;second\n\nwrong;row\n1000;duplicate\n",
+			addMockFc: func(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error) {
+				if slug != "test-slug" || len(usersIDs) != 2 || deleteAt != nil {
+					return nil, fmt.Errorf("unexpected users")
+				}
+				return &model.BulkResult{Added: 1, Skipped: 1}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `{"slug": "test-slug", "operation": "add", "added": 1, "removed": 0, "skipped": 2, "invalid": 1}`,
+		},
+		{
+			name:        "add users with ttl",
+			inputFields: map[string]string{"slug": "test-slug", "ttl_seconds": "172800"},
+			inputFile:   "1000\n1002\n1004\n",
+			addMockFc: func(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error) {
+				if deleteAt == nil || deleteAt.Before(time.Now().Add(47*time.Hour)) {
+					return nil, fmt.Errorf("unexpected ttl")
+				}
+				return &model.BulkResult{Added: 3}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `{"slug": "test-slug", "operation": "add", "added": 3, "removed": 0, "skipped": 0, "invalid": 0}`,
+		},
+		{
+			name:        "remove users",
+			inputFields: map[string]string{"slug": "test-slug", "operation": "remove"},
+			inputFile:   "1000\n1002\n",
+			removeMockFc: func(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error) {
+				return &model.BulkResult{Removed: 1, Skipped: 1}, nil
+			},
+			expectedCode: http.StatusOK,
+			expectedResp: `{"slug": "test-slug", "operation": "remove", "added": 0, "removed": 1, "skipped": 1, "invalid": 0}`,
+		},
+		{
+			name:         "invalid request body (slug)",
+			inputFile:    "1000\n",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid request body (operation)",
+			inputFields:  map[string]string{"slug": "test-slug", "operation": "wrong"},
+			inputFile:    "1000\n",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid request body (ttl_seconds overflow)",
+			inputFields:  map[string]string{"slug": "test-slug", "ttl_seconds": "10000000000"},
+			inputFile:    "1000\n",
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `{"error": {"code": "validation_failed", "message": "ttl_seconds must be within 0 and 315360000"}}`,
+		},
+		{
+			name:         "invalid request body (delete_at overflow)",
+			inputFields:  map[string]string{"slug": "test-slug", "delete_at": "9223372036854775807"},
+			inputFile:    "1000\n",
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `{"error": {"code": "validation_failed", "message": "delete_at must be within 315360000 seconds from the start"}}`,
+		},
+		{
+			name:         "invalid request body (delete_at and ttl_seconds)",
+			inputFields:  map[string]string{"slug": "test-slug", "delete_at": "1853805983", "ttl_seconds": "60"},
+			inputFile:    "1000\n",
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `{"error": {"code": "validation_failed", "message": "only one of delete_at and ttl_seconds can be set"}}`,
+		},
+		{
+			name:         "no valid users",
+			inputFields:  map[string]string{"slug": "test-slug"},
+			inputFile:    "user_id\nwrong\n-1\n",
+			expectedCode: http.StatusBadRequest,
+			expectedResp: `{"error": {"code": "validation_failed", "message": "no valid users ids in file"}, "invalid": 2}`,
+		},
+		{
+			name:        "segment not found",
+			inputFields: map[string]string{"slug": "test-slug"},
+			inputFile:   "1000\n",
+			addMockFc: func(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error) {
+				return nil, database.ErrNotFound
+			},
+			expectedCode: http.StatusNotFound,
+			expectedResp: `{"error": {"code": "not_found", "message": "segment test-slug not found"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			if tc.addMockFc != nil {
+				s.repo.AddSegmentUsersFunc = tc.addMockFc
+			}
+			if tc.removeMockFc != nil {
+				s.repo.RemoveSegmentUsersFunc = tc.removeMockFc
+			}
+
+			var b bytes.Buffer
+			w := multipart.NewWriter(&b)
+			for k, v := range tc.inputFields {
+				_ = w.WriteField(k, v)
+			}
+			fw, _ := w.CreateFormFile("file", "users.csv")
+			_, _ = fw.Write([]byte(tc.inputFile))
+			_ = w.Close()
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/user/segment/upload", &b)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			s.r.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+
+			if tc.expectedResp != "" {
+				assert.JSONEq(t, tc.expectedResp, res.Body.String())
+			}
+		})
+	}
+}
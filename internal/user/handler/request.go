@@ -1,21 +1,79 @@
 package handler
 
+import "encoding/json"
+
 type GetUserSegmentsUri struct {
 	UserID uint `uri:"user_id" binding:"required"`
 }
 
+type CreateUserRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+type GetUserProfileUri struct {
+	UserID uint `uri:"user_id" binding:"required"`
+}
+
+type DeleteUserUri struct {
+	UserID uint `uri:"user_id" binding:"required"`
+}
+
 type GetUserHistoryUri struct {
 	UserID uint `uri:"user_id" binding:"required"`
 }
 
 type GetUserHistoryQuery struct {
-	Month uint `form:"month" binding:"required"`
+	Month uint `form:"month" binding:"required,min=1,max=12"`
 	Year  uint `form:"year" binding:"required"`
 }
 
 type UpdateUserSegmentsRequest struct {
-	UserID     uint     `json:"user_id" binding:"required"`
-	SlugsToAdd []string `json:"slugs_to_add" binding:"required"`
-	SlugsToDel []string `json:"slugs_to_del" binding:"required"`
-	DeleteAt   int64    `json:"delete_at"`
+	UserID     uint        `json:"user_id" binding:"required"`
+	SlugsToAdd []SlugToAdd `json:"slugs_to_add" binding:"required,dive"`
+	SlugsToDel []string    `json:"slugs_to_del" binding:"required,dive,required,max=50"`
+	ActiveFrom int64       `json:"active_from"`
+	DeleteAt   int64       `json:"delete_at"`
+	// Strict rejects the whole update if any slug is unknown
+	Strict bool `json:"strict"`
+}
+
+type UpdateUsersSegmentsRequest struct {
+	Updates []UpdateUserSegmentsRequest `json:"updates" binding:"required,min=1,max=10000,dive"`
+}
+
+type LookupUsersSegmentsRequest struct {
+	UsersIDs []uint `json:"user_ids" binding:"required,min=1,max=5000,dive,required"`
+}
+
+// SlugToAdd is either a plain slug or an object with its own start and TTL,
+// slugs without them fall back to request active_from and delete_at
+type SlugToAdd struct {
+	Slug       string `json:"slug" binding:"required,max=50"`
+	ActiveFrom int64  `json:"active_from"`
+	DeleteAt   int64  `json:"delete_at"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+func (s *SlugToAdd) UnmarshalJSON(data []byte) error {
+	var slug string
+	if err := json.Unmarshal(data, &slug); err == nil {
+		*s = SlugToAdd{Slug: slug}
+		return nil
+	}
+
+	type slugToAdd SlugToAdd
+	var tmp slugToAdd
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*s = SlugToAdd(tmp)
+
+	return nil
+}
+
+type UploadSegmentUsersRequest struct {
+	Slug       string `form:"slug" binding:"required,max=50"`
+	Operation  string `form:"operation,default=add" binding:"oneof=add remove"`
+	DeleteAt   int64  `form:"delete_at"`
+	TTLSeconds int64  `form:"ttl_seconds" binding:"min=0"`
 }
@@ -2,6 +2,19 @@ package model
 
 import (
 	"time"
+
+	sModel "avito_2023/internal/segment/model"
+)
+
+const (
+	OperationAdd    = "add"
+	OperationRemove = "remove"
+
+	SourceManual     = "manual"
+	SourceBulk       = "bulk"
+	SourcePercentage = "percentage"
+	SourceTTL        = "ttl"
+	SourceArchive    = "archive"
 )
 
 type UserDB struct {
@@ -13,20 +26,125 @@ func (UserDB) TableName() string {
 	return "users"
 }
 
+// UserProfile describes registered user and its memberships
+type UserProfile struct {
+	UserID            uint       `json:"user_id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ActiveSegments    []string   `json:"active_segments"`
+	ScheduledSegments []string   `json:"scheduled_segments"`
+	LastChangeAt      *time.Time `json:"last_change_at"`
+}
+
 type UserSegmentDB struct {
-	ID        uint       `gorm:"id"`
-	UserID    uint       `gorm:"user_id"`
-	SegmentID uint       `gorm:"segment_id"`
-	CreatedAt time.Time  `gorm:"created_at"`
-	DeletedAt *time.Time `gorm:"deleted_at"`
+	ID         uint       `gorm:"id"`
+	UserID     uint       `gorm:"user_id"`
+	SegmentID  uint       `gorm:"segment_id"`
+	CreatedAt  time.Time  `gorm:"created_at"`
+	ActiveFrom *time.Time `gorm:"active_from"`
+	DeletedAt  *time.Time `gorm:"deleted_at"`
+	// Pending is set until the scheduled start is recorded in segment_events
+	Pending   bool   `gorm:"pending"`
+	Finalized bool   `gorm:"finalized"`
+	Source    string `gorm:"source"`
 }
 
 func (UserSegmentDB) TableName() string {
 	return "users_segments"
 }
 
+// SegmentEventDB is an append-only record of user joining or leaving a segment
+type SegmentEventDB struct {
+	ID          uint      `gorm:"id"`
+	UserID      uint      `gorm:"user_id"`
+	SegmentID   *uint     `gorm:"segment_id"`
+	SegmentSlug string    `gorm:"segment_slug"`
+	Operation   string    `gorm:"operation"`
+	Source      string    `gorm:"source"`
+	CreatedAt   time.Time `gorm:"created_at"`
+}
+
+func (SegmentEventDB) TableName() string {
+	return "segment_events"
+}
+
+// NewSegmentEvent returns history event of user joining or leaving the segment
+func NewSegmentEvent(userID uint, segment *sModel.SegmentDB, operation, source string) *SegmentEventDB {
+	return &SegmentEventDB{
+		UserID:      userID,
+		SegmentID:   &segment.ID,
+		SegmentSlug: segment.Slug,
+		Operation:   operation,
+		Source:      source,
+	}
+}
+
+type ExpiredUserSegment struct {
+	ID        uint      `gorm:"id"`
+	UserID    uint      `gorm:"user_id"`
+	SegmentID uint      `gorm:"segment_id"`
+	Slug      string    `gorm:"slug"`
+	DeletedAt time.Time `gorm:"deleted_at"`
+}
+
+// ActivatedUserSegment is a scheduled membership which has started
+type ActivatedUserSegment struct {
+	ID         uint      `gorm:"id"`
+	UserID     uint      `gorm:"user_id"`
+	SegmentID  uint      `gorm:"segment_id"`
+	Slug       string    `gorm:"slug"`
+	Source     string    `gorm:"source"`
+	ActiveFrom time.Time `gorm:"active_from"`
+}
+
+// SegmentToAdd is a segment added to user with its own TTL and optional scheduled start
+type SegmentToAdd struct {
+	Slug       string
+	ActiveFrom *time.Time
+	DeleteAt   *time.Time
+}
+
+// UserSegmentsUpdate adds and removes segments of a single user
+type UserSegmentsUpdate struct {
+	UserID     uint
+	SlugsToAdd []*SegmentToAdd
+	SlugsToDel []string
+	// Strict rejects the whole update if any slug is unknown
+	Strict bool
+}
+
+const (
+	SlugStatusAdded          = "added"
+	SlugStatusAlreadyMember  = "already_member"
+	SlugStatusRemoved        = "removed"
+	SlugStatusNotMember      = "not_member"
+	SlugStatusUnknownSegment = "unknown_segment"
+	SlugStatusArchived       = "archived"
+)
+
+// SlugResult is the outcome of adding or removing a single segment
+type SlugResult struct {
+	Slug      string `json:"slug"`
+	Operation string `json:"operation"`
+	Status    string `json:"status"`
+}
+
+// UserSegmentsResult is the outcome of a single user update in batch
+type UserSegmentsResult struct {
+	UserID  uint          `json:"user_id"`
+	Results []*SlugResult `json:"results"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// BulkResult summarizes bulk segment update
+type BulkResult struct {
+	Added   uint `json:"added"`
+	Removed uint `json:"removed"`
+	Skipped uint `json:"skipped"`
+}
+
 type UserHistory struct {
-	Slug      string     `gorm:"slug" json:"slug"`
-	CreatedAt time.Time  `gorm:"created_at" json:"created_at"`
-	DeletedAt *time.Time `gorm:"deleted_at" json:"deleted_at"`
+	Slug      string    `gorm:"slug" json:"slug"`
+	Operation string    `gorm:"operation" json:"operation"`
+	Source    string    `gorm:"source" json:"source"`
+	CreatedAt time.Time `gorm:"created_at" json:"created_at"`
 }
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"avito_2023/internal/database"
+	"avito_2023/internal/user/model"
+)
+
+// instrumentedRepo attributes queries of every repo method to it and reports the method
+// to hooks registered with database.AddMethodHook
+type instrumentedRepo struct {
+	repo Repo
+}
+
+func (r *instrumentedRepo) GetUserSegments(ctx context.Context, userID uint) (result []*string, err error) {
+	ctx, end := database.StartMethod(ctx, "user.GetUserSegments")
+	defer func() { end(err) }()
+
+	return r.repo.GetUserSegments(ctx, userID)
+}
+
+func (r *instrumentedRepo) CreateUser(ctx context.Context, userID uint) (result *model.UserDB, err error) {
+	ctx, end := database.StartMethod(ctx, "user.CreateUser")
+	defer func() { end(err) }()
+
+	return r.repo.CreateUser(ctx, userID)
+}
+
+func (r *instrumentedRepo) GetUserProfile(ctx context.Context, userID uint) (result *model.UserProfile, err error) {
+	ctx, end := database.StartMethod(ctx, "user.GetUserProfile")
+	defer func() { end(err) }()
+
+	return r.repo.GetUserProfile(ctx, userID)
+}
+
+func (r *instrumentedRepo) DeleteUser(ctx context.Context, userID uint) (err error) {
+	ctx, end := database.StartMethod(ctx, "user.DeleteUser")
+	defer func() { end(err) }()
+
+	return r.repo.DeleteUser(ctx, userID)
+}
+
+func (r *instrumentedRepo) LookupUsersSegments(ctx context.Context, usersIDs []uint) (result map[uint][]string, err error) {
+	ctx, end := database.StartMethod(ctx, "user.LookupUsersSegments")
+	defer func() { end(err) }()
+
+	return r.repo.LookupUsersSegments(ctx, usersIDs)
+}
+
+func (r *instrumentedRepo) GetUserHistory(ctx context.Context, userID uint, month uint, year uint) (result []*model.UserHistory, err error) {
+	ctx, end := database.StartMethod(ctx, "user.GetUserHistory")
+	defer func() { end(err) }()
+
+	return r.repo.GetUserHistory(ctx, userID, month, year)
+}
+
+func (r *instrumentedRepo) UpdateUserSegments(ctx context.Context, update *model.UserSegmentsUpdate) (result []*model.SlugResult, err error) {
+	ctx, end := database.StartMethod(ctx, "user.UpdateUserSegments")
+	defer func() { end(err) }()
+
+	return r.repo.UpdateUserSegments(ctx, update)
+}
+
+func (r *instrumentedRepo) UpdateUsersSegments(ctx context.Context, updates []*model.UserSegmentsUpdate) (result []*model.UserSegmentsResult, err error) {
+	ctx, end := database.StartMethod(ctx, "user.UpdateUsersSegments")
+	defer func() { end(err) }()
+
+	return r.repo.UpdateUsersSegments(ctx, updates)
+}
+
+func (r *instrumentedRepo) AddSegmentUsers(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (result *model.BulkResult, err error) {
+	ctx, end := database.StartMethod(ctx, "user.AddSegmentUsers")
+	defer func() { end(err) }()
+
+	return r.repo.AddSegmentUsers(ctx, slug, usersIDs, deleteAt)
+}
+
+func (r *instrumentedRepo) RemoveSegmentUsers(ctx context.Context, slug string, usersIDs []uint) (result *model.BulkResult, err error) {
+	ctx, end := database.StartMethod(ctx, "user.RemoveSegmentUsers")
+	defer func() { end(err) }()
+
+	return r.repo.RemoveSegmentUsers(ctx, slug, usersIDs)
+}
+
+func (r *instrumentedRepo) ExpireUserSegments(ctx context.Context, limit int) (result []*model.ExpiredUserSegment, err error) {
+	ctx, end := database.StartMethod(ctx, "user.ExpireUserSegments")
+	defer func() { end(err) }()
+
+	return r.repo.ExpireUserSegments(ctx, limit)
+}
+
+func (r *instrumentedRepo) ActivateUserSegments(ctx context.Context, limit int) (result []*model.ActivatedUserSegment, err error) {
+	ctx, end := database.StartMethod(ctx, "user.ActivateUserSegments")
+	defer func() { end(err) }()
+
+	return r.repo.ActivateUserSegments(ctx, limit)
+}
+
+func (r *instrumentedRepo) GetOldestDue(ctx context.Context) (result *time.Time, err error) {
+	ctx, end := database.StartMethod(ctx, "user.GetOldestDue")
+	defer func() { end(err) }()
+
+	return r.repo.GetOldestDue(ctx)
+}
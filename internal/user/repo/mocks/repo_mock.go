@@ -21,15 +21,45 @@ var _ repo.Repo = &RepoMock{}
 //
 //		// make and configure a mocked repo.Repo
 //		mockedRepo := &RepoMock{
+//			ActivateUserSegmentsFunc: func(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error) {
+//				panic("mock out the ActivateUserSegments method")
+//			},
+//			AddSegmentUsersFunc: func(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error) {
+//				panic("mock out the AddSegmentUsers method")
+//			},
+//			CreateUserFunc: func(ctx context.Context, userID uint) (*model.UserDB, error) {
+//				panic("mock out the CreateUser method")
+//			},
+//			DeleteUserFunc: func(ctx context.Context, userID uint) error {
+//				panic("mock out the DeleteUser method")
+//			},
+//			ExpireUserSegmentsFunc: func(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error) {
+//				panic("mock out the ExpireUserSegments method")
+//			},
+//			GetOldestDueFunc: func(ctx context.Context) (*time.Time, error) {
+//				panic("mock out the GetOldestDue method")
+//			},
 //			GetUserHistoryFunc: func(ctx context.Context, userID uint, month uint, year uint) ([]*model.UserHistory, error) {
 //				panic("mock out the GetUserHistory method")
 //			},
+//			GetUserProfileFunc: func(ctx context.Context, userID uint) (*model.UserProfile, error) {
+//				panic("mock out the GetUserProfile method")
+//			},
 //			GetUserSegmentsFunc: func(ctx context.Context, userID uint) ([]*string, error) {
 //				panic("mock out the GetUserSegments method")
 //			},
-//			UpdateUserSegmentsFunc: func(ctx context.Context, userID uint, slugsToAdd []string, slugsToDel []string, deleteAt *time.Time) error {
+//			LookupUsersSegmentsFunc: func(ctx context.Context, usersIDs []uint) (map[uint][]string, error) {
+//				panic("mock out the LookupUsersSegments method")
+//			},
+//			RemoveSegmentUsersFunc: func(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error) {
+//				panic("mock out the RemoveSegmentUsers method")
+//			},
+//			UpdateUserSegmentsFunc: func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
 //				panic("mock out the UpdateUserSegments method")
 //			},
+//			UpdateUsersSegmentsFunc: func(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error) {
+//				panic("mock out the UpdateUsersSegments method")
+//			},
 //		}
 //
 //		// use mockedRepo in code that requires repo.Repo
@@ -37,17 +67,91 @@ var _ repo.Repo = &RepoMock{}
 //
 //	}
 type RepoMock struct {
+	// ActivateUserSegmentsFunc mocks the ActivateUserSegments method.
+	ActivateUserSegmentsFunc func(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error)
+
+	// AddSegmentUsersFunc mocks the AddSegmentUsers method.
+	AddSegmentUsersFunc func(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error)
+
+	// CreateUserFunc mocks the CreateUser method.
+	CreateUserFunc func(ctx context.Context, userID uint) (*model.UserDB, error)
+
+	// DeleteUserFunc mocks the DeleteUser method.
+	DeleteUserFunc func(ctx context.Context, userID uint) error
+
+	// ExpireUserSegmentsFunc mocks the ExpireUserSegments method.
+	ExpireUserSegmentsFunc func(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error)
+
+	// GetOldestDueFunc mocks the GetOldestDue method.
+	GetOldestDueFunc func(ctx context.Context) (*time.Time, error)
+
 	// GetUserHistoryFunc mocks the GetUserHistory method.
 	GetUserHistoryFunc func(ctx context.Context, userID uint, month uint, year uint) ([]*model.UserHistory, error)
 
+	// GetUserProfileFunc mocks the GetUserProfile method.
+	GetUserProfileFunc func(ctx context.Context, userID uint) (*model.UserProfile, error)
+
 	// GetUserSegmentsFunc mocks the GetUserSegments method.
 	GetUserSegmentsFunc func(ctx context.Context, userID uint) ([]*string, error)
 
+	// LookupUsersSegmentsFunc mocks the LookupUsersSegments method.
+	LookupUsersSegmentsFunc func(ctx context.Context, usersIDs []uint) (map[uint][]string, error)
+
+	// RemoveSegmentUsersFunc mocks the RemoveSegmentUsers method.
+	RemoveSegmentUsersFunc func(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error)
+
 	// UpdateUserSegmentsFunc mocks the UpdateUserSegments method.
-	UpdateUserSegmentsFunc func(ctx context.Context, userID uint, slugsToAdd []string, slugsToDel []string, deleteAt *time.Time) error
+	UpdateUserSegmentsFunc func(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error)
+
+	// UpdateUsersSegmentsFunc mocks the UpdateUsersSegments method.
+	UpdateUsersSegmentsFunc func(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// ActivateUserSegments holds details about calls to the ActivateUserSegments method.
+		ActivateUserSegments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// AddSegmentUsers holds details about calls to the AddSegmentUsers method.
+		AddSegmentUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Slug is the slug argument value.
+			Slug string
+			// UsersIDs is the usersIDs argument value.
+			UsersIDs []uint
+			// DeleteAt is the deleteAt argument value.
+			DeleteAt *time.Time
+		}
+		// CreateUser holds details about calls to the CreateUser method.
+		CreateUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID uint
+		}
+		// DeleteUser holds details about calls to the DeleteUser method.
+		DeleteUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID uint
+		}
+		// ExpireUserSegments holds details about calls to the ExpireUserSegments method.
+		ExpireUserSegments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetOldestDue holds details about calls to the GetOldestDue method.
+		GetOldestDue []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// GetUserHistory holds details about calls to the GetUserHistory method.
 		GetUserHistory []struct {
 			// Ctx is the ctx argument value.
@@ -59,6 +163,13 @@ type RepoMock struct {
 			// Year is the year argument value.
 			Year uint
 		}
+		// GetUserProfile holds details about calls to the GetUserProfile method.
+		GetUserProfile []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID uint
+		}
 		// GetUserSegments holds details about calls to the GetUserSegments method.
 		GetUserSegments []struct {
 			// Ctx is the ctx argument value.
@@ -66,23 +177,270 @@ type RepoMock struct {
 			// UserID is the userID argument value.
 			UserID uint
 		}
+		// LookupUsersSegments holds details about calls to the LookupUsersSegments method.
+		LookupUsersSegments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UsersIDs is the usersIDs argument value.
+			UsersIDs []uint
+		}
+		// RemoveSegmentUsers holds details about calls to the RemoveSegmentUsers method.
+		RemoveSegmentUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Slug is the slug argument value.
+			Slug string
+			// UsersIDs is the usersIDs argument value.
+			UsersIDs []uint
+		}
 		// UpdateUserSegments holds details about calls to the UpdateUserSegments method.
 		UpdateUserSegments []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// UserID is the userID argument value.
-			UserID uint
-			// SlugsToAdd is the slugsToAdd argument value.
-			SlugsToAdd []string
-			// SlugsToDel is the slugsToDel argument value.
-			SlugsToDel []string
-			// DeleteAt is the deleteAt argument value.
-			DeleteAt *time.Time
+			// Update is the update argument value.
+			Update *model.UserSegmentsUpdate
+		}
+		// UpdateUsersSegments holds details about calls to the UpdateUsersSegments method.
+		UpdateUsersSegments []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Updates is the updates argument value.
+			Updates []*model.UserSegmentsUpdate
 		}
 	}
-	lockGetUserHistory     sync.RWMutex
-	lockGetUserSegments    sync.RWMutex
-	lockUpdateUserSegments sync.RWMutex
+	lockActivateUserSegments sync.RWMutex
+	lockAddSegmentUsers      sync.RWMutex
+	lockCreateUser           sync.RWMutex
+	lockDeleteUser           sync.RWMutex
+	lockExpireUserSegments   sync.RWMutex
+	lockGetOldestDue         sync.RWMutex
+	lockGetUserHistory       sync.RWMutex
+	lockGetUserProfile       sync.RWMutex
+	lockGetUserSegments      sync.RWMutex
+	lockLookupUsersSegments  sync.RWMutex
+	lockRemoveSegmentUsers   sync.RWMutex
+	lockUpdateUserSegments   sync.RWMutex
+	lockUpdateUsersSegments  sync.RWMutex
+}
+
+// ActivateUserSegments calls ActivateUserSegmentsFunc.
+func (mock *RepoMock) ActivateUserSegments(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error) {
+	if mock.ActivateUserSegmentsFunc == nil {
+		panic("RepoMock.ActivateUserSegmentsFunc: method is nil but Repo.ActivateUserSegments was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Limit int
+	}{
+		Ctx:   ctx,
+		Limit: limit,
+	}
+	mock.lockActivateUserSegments.Lock()
+	mock.calls.ActivateUserSegments = append(mock.calls.ActivateUserSegments, callInfo)
+	mock.lockActivateUserSegments.Unlock()
+	return mock.ActivateUserSegmentsFunc(ctx, limit)
+}
+
+// ActivateUserSegmentsCalls gets all the calls that were made to ActivateUserSegments.
+// Check the length with:
+//
+//	len(mockedRepo.ActivateUserSegmentsCalls())
+func (mock *RepoMock) ActivateUserSegmentsCalls() []struct {
+	Ctx   context.Context
+	Limit int
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Limit int
+	}
+	mock.lockActivateUserSegments.RLock()
+	calls = mock.calls.ActivateUserSegments
+	mock.lockActivateUserSegments.RUnlock()
+	return calls
+}
+
+// AddSegmentUsers calls AddSegmentUsersFunc.
+func (mock *RepoMock) AddSegmentUsers(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error) {
+	if mock.AddSegmentUsersFunc == nil {
+		panic("RepoMock.AddSegmentUsersFunc: method is nil but Repo.AddSegmentUsers was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Slug     string
+		UsersIDs []uint
+		DeleteAt *time.Time
+	}{
+		Ctx:      ctx,
+		Slug:     slug,
+		UsersIDs: usersIDs,
+		DeleteAt: deleteAt,
+	}
+	mock.lockAddSegmentUsers.Lock()
+	mock.calls.AddSegmentUsers = append(mock.calls.AddSegmentUsers, callInfo)
+	mock.lockAddSegmentUsers.Unlock()
+	return mock.AddSegmentUsersFunc(ctx, slug, usersIDs, deleteAt)
+}
+
+// AddSegmentUsersCalls gets all the calls that were made to AddSegmentUsers.
+// Check the length with:
+//
+//	len(mockedRepo.AddSegmentUsersCalls())
+func (mock *RepoMock) AddSegmentUsersCalls() []struct {
+	Ctx      context.Context
+	Slug     string
+	UsersIDs []uint
+	DeleteAt *time.Time
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Slug     string
+		UsersIDs []uint
+		DeleteAt *time.Time
+	}
+	mock.lockAddSegmentUsers.RLock()
+	calls = mock.calls.AddSegmentUsers
+	mock.lockAddSegmentUsers.RUnlock()
+	return calls
+}
+
+// CreateUser calls CreateUserFunc.
+func (mock *RepoMock) CreateUser(ctx context.Context, userID uint) (*model.UserDB, error) {
+	if mock.CreateUserFunc == nil {
+		panic("RepoMock.CreateUserFunc: method is nil but Repo.CreateUser was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		UserID uint
+	}{
+		Ctx:    ctx,
+		UserID: userID,
+	}
+	mock.lockCreateUser.Lock()
+	mock.calls.CreateUser = append(mock.calls.CreateUser, callInfo)
+	mock.lockCreateUser.Unlock()
+	return mock.CreateUserFunc(ctx, userID)
+}
+
+// CreateUserCalls gets all the calls that were made to CreateUser.
+// Check the length with:
+//
+//	len(mockedRepo.CreateUserCalls())
+func (mock *RepoMock) CreateUserCalls() []struct {
+	Ctx    context.Context
+	UserID uint
+} {
+	var calls []struct {
+		Ctx    context.Context
+		UserID uint
+	}
+	mock.lockCreateUser.RLock()
+	calls = mock.calls.CreateUser
+	mock.lockCreateUser.RUnlock()
+	return calls
+}
+
+// DeleteUser calls DeleteUserFunc.
+func (mock *RepoMock) DeleteUser(ctx context.Context, userID uint) error {
+	if mock.DeleteUserFunc == nil {
+		panic("RepoMock.DeleteUserFunc: method is nil but Repo.DeleteUser was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		UserID uint
+	}{
+		Ctx:    ctx,
+		UserID: userID,
+	}
+	mock.lockDeleteUser.Lock()
+	mock.calls.DeleteUser = append(mock.calls.DeleteUser, callInfo)
+	mock.lockDeleteUser.Unlock()
+	return mock.DeleteUserFunc(ctx, userID)
+}
+
+// DeleteUserCalls gets all the calls that were made to DeleteUser.
+// Check the length with:
+//
+//	len(mockedRepo.DeleteUserCalls())
+func (mock *RepoMock) DeleteUserCalls() []struct {
+	Ctx    context.Context
+	UserID uint
+} {
+	var calls []struct {
+		Ctx    context.Context
+		UserID uint
+	}
+	mock.lockDeleteUser.RLock()
+	calls = mock.calls.DeleteUser
+	mock.lockDeleteUser.RUnlock()
+	return calls
+}
+
+// ExpireUserSegments calls ExpireUserSegmentsFunc.
+func (mock *RepoMock) ExpireUserSegments(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error) {
+	if mock.ExpireUserSegmentsFunc == nil {
+		panic("RepoMock.ExpireUserSegmentsFunc: method is nil but Repo.ExpireUserSegments was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Limit int
+	}{
+		Ctx:   ctx,
+		Limit: limit,
+	}
+	mock.lockExpireUserSegments.Lock()
+	mock.calls.ExpireUserSegments = append(mock.calls.ExpireUserSegments, callInfo)
+	mock.lockExpireUserSegments.Unlock()
+	return mock.ExpireUserSegmentsFunc(ctx, limit)
+}
+
+// ExpireUserSegmentsCalls gets all the calls that were made to ExpireUserSegments.
+// Check the length with:
+//
+//	len(mockedRepo.ExpireUserSegmentsCalls())
+func (mock *RepoMock) ExpireUserSegmentsCalls() []struct {
+	Ctx   context.Context
+	Limit int
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Limit int
+	}
+	mock.lockExpireUserSegments.RLock()
+	calls = mock.calls.ExpireUserSegments
+	mock.lockExpireUserSegments.RUnlock()
+	return calls
+}
+
+// GetOldestDue calls GetOldestDueFunc.
+func (mock *RepoMock) GetOldestDue(ctx context.Context) (*time.Time, error) {
+	if mock.GetOldestDueFunc == nil {
+		panic("RepoMock.GetOldestDueFunc: method is nil but Repo.GetOldestDue was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockGetOldestDue.Lock()
+	mock.calls.GetOldestDue = append(mock.calls.GetOldestDue, callInfo)
+	mock.lockGetOldestDue.Unlock()
+	return mock.GetOldestDueFunc(ctx)
+}
+
+// GetOldestDueCalls gets all the calls that were made to GetOldestDue.
+// Check the length with:
+//
+//	len(mockedRepo.GetOldestDueCalls())
+func (mock *RepoMock) GetOldestDueCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockGetOldestDue.RLock()
+	calls = mock.calls.GetOldestDue
+	mock.lockGetOldestDue.RUnlock()
+	return calls
 }
 
 // GetUserHistory calls GetUserHistoryFunc.
@@ -129,6 +487,42 @@ func (mock *RepoMock) GetUserHistoryCalls() []struct {
 	return calls
 }
 
+// GetUserProfile calls GetUserProfileFunc.
+func (mock *RepoMock) GetUserProfile(ctx context.Context, userID uint) (*model.UserProfile, error) {
+	if mock.GetUserProfileFunc == nil {
+		panic("RepoMock.GetUserProfileFunc: method is nil but Repo.GetUserProfile was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		UserID uint
+	}{
+		Ctx:    ctx,
+		UserID: userID,
+	}
+	mock.lockGetUserProfile.Lock()
+	mock.calls.GetUserProfile = append(mock.calls.GetUserProfile, callInfo)
+	mock.lockGetUserProfile.Unlock()
+	return mock.GetUserProfileFunc(ctx, userID)
+}
+
+// GetUserProfileCalls gets all the calls that were made to GetUserProfile.
+// Check the length with:
+//
+//	len(mockedRepo.GetUserProfileCalls())
+func (mock *RepoMock) GetUserProfileCalls() []struct {
+	Ctx    context.Context
+	UserID uint
+} {
+	var calls []struct {
+		Ctx    context.Context
+		UserID uint
+	}
+	mock.lockGetUserProfile.RLock()
+	calls = mock.calls.GetUserProfile
+	mock.lockGetUserProfile.RUnlock()
+	return calls
+}
+
 // GetUserSegments calls GetUserSegmentsFunc.
 func (mock *RepoMock) GetUserSegments(ctx context.Context, userID uint) ([]*string, error) {
 	if mock.GetUserSegmentsFunc == nil {
@@ -165,28 +559,98 @@ func (mock *RepoMock) GetUserSegmentsCalls() []struct {
 	return calls
 }
 
+// LookupUsersSegments calls LookupUsersSegmentsFunc.
+func (mock *RepoMock) LookupUsersSegments(ctx context.Context, usersIDs []uint) (map[uint][]string, error) {
+	if mock.LookupUsersSegmentsFunc == nil {
+		panic("RepoMock.LookupUsersSegmentsFunc: method is nil but Repo.LookupUsersSegments was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		UsersIDs []uint
+	}{
+		Ctx:      ctx,
+		UsersIDs: usersIDs,
+	}
+	mock.lockLookupUsersSegments.Lock()
+	mock.calls.LookupUsersSegments = append(mock.calls.LookupUsersSegments, callInfo)
+	mock.lockLookupUsersSegments.Unlock()
+	return mock.LookupUsersSegmentsFunc(ctx, usersIDs)
+}
+
+// LookupUsersSegmentsCalls gets all the calls that were made to LookupUsersSegments.
+// Check the length with:
+//
+//	len(mockedRepo.LookupUsersSegmentsCalls())
+func (mock *RepoMock) LookupUsersSegmentsCalls() []struct {
+	Ctx      context.Context
+	UsersIDs []uint
+} {
+	var calls []struct {
+		Ctx      context.Context
+		UsersIDs []uint
+	}
+	mock.lockLookupUsersSegments.RLock()
+	calls = mock.calls.LookupUsersSegments
+	mock.lockLookupUsersSegments.RUnlock()
+	return calls
+}
+
+// RemoveSegmentUsers calls RemoveSegmentUsersFunc.
+func (mock *RepoMock) RemoveSegmentUsers(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error) {
+	if mock.RemoveSegmentUsersFunc == nil {
+		panic("RepoMock.RemoveSegmentUsersFunc: method is nil but Repo.RemoveSegmentUsers was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Slug     string
+		UsersIDs []uint
+	}{
+		Ctx:      ctx,
+		Slug:     slug,
+		UsersIDs: usersIDs,
+	}
+	mock.lockRemoveSegmentUsers.Lock()
+	mock.calls.RemoveSegmentUsers = append(mock.calls.RemoveSegmentUsers, callInfo)
+	mock.lockRemoveSegmentUsers.Unlock()
+	return mock.RemoveSegmentUsersFunc(ctx, slug, usersIDs)
+}
+
+// RemoveSegmentUsersCalls gets all the calls that were made to RemoveSegmentUsers.
+// Check the length with:
+//
+//	len(mockedRepo.RemoveSegmentUsersCalls())
+func (mock *RepoMock) RemoveSegmentUsersCalls() []struct {
+	Ctx      context.Context
+	Slug     string
+	UsersIDs []uint
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Slug     string
+		UsersIDs []uint
+	}
+	mock.lockRemoveSegmentUsers.RLock()
+	calls = mock.calls.RemoveSegmentUsers
+	mock.lockRemoveSegmentUsers.RUnlock()
+	return calls
+}
+
 // UpdateUserSegments calls UpdateUserSegmentsFunc.
-func (mock *RepoMock) UpdateUserSegments(ctx context.Context, userID uint, slugsToAdd []string, slugsToDel []string, deleteAt *time.Time) error {
+func (mock *RepoMock) UpdateUserSegments(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
 	if mock.UpdateUserSegmentsFunc == nil {
 		panic("RepoMock.UpdateUserSegmentsFunc: method is nil but Repo.UpdateUserSegments was just called")
 	}
 	callInfo := struct {
-		Ctx        context.Context
-		UserID     uint
-		SlugsToAdd []string
-		SlugsToDel []string
-		DeleteAt   *time.Time
+		Ctx    context.Context
+		Update *model.UserSegmentsUpdate
 	}{
-		Ctx:        ctx,
-		UserID:     userID,
-		SlugsToAdd: slugsToAdd,
-		SlugsToDel: slugsToDel,
-		DeleteAt:   deleteAt,
+		Ctx:    ctx,
+		Update: update,
 	}
 	mock.lockUpdateUserSegments.Lock()
 	mock.calls.UpdateUserSegments = append(mock.calls.UpdateUserSegments, callInfo)
 	mock.lockUpdateUserSegments.Unlock()
-	return mock.UpdateUserSegmentsFunc(ctx, userID, slugsToAdd, slugsToDel, deleteAt)
+	return mock.UpdateUserSegmentsFunc(ctx, update)
 }
 
 // UpdateUserSegmentsCalls gets all the calls that were made to UpdateUserSegments.
@@ -194,21 +658,51 @@ func (mock *RepoMock) UpdateUserSegments(ctx context.Context, userID uint, slugs
 //
 //	len(mockedRepo.UpdateUserSegmentsCalls())
 func (mock *RepoMock) UpdateUserSegmentsCalls() []struct {
-	Ctx        context.Context
-	UserID     uint
-	SlugsToAdd []string
-	SlugsToDel []string
-	DeleteAt   *time.Time
+	Ctx    context.Context
+	Update *model.UserSegmentsUpdate
 } {
 	var calls []struct {
-		Ctx        context.Context
-		UserID     uint
-		SlugsToAdd []string
-		SlugsToDel []string
-		DeleteAt   *time.Time
+		Ctx    context.Context
+		Update *model.UserSegmentsUpdate
 	}
 	mock.lockUpdateUserSegments.RLock()
 	calls = mock.calls.UpdateUserSegments
 	mock.lockUpdateUserSegments.RUnlock()
 	return calls
 }
+
+// UpdateUsersSegments calls UpdateUsersSegmentsFunc.
+func (mock *RepoMock) UpdateUsersSegments(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error) {
+	if mock.UpdateUsersSegmentsFunc == nil {
+		panic("RepoMock.UpdateUsersSegmentsFunc: method is nil but Repo.UpdateUsersSegments was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Updates []*model.UserSegmentsUpdate
+	}{
+		Ctx:     ctx,
+		Updates: updates,
+	}
+	mock.lockUpdateUsersSegments.Lock()
+	mock.calls.UpdateUsersSegments = append(mock.calls.UpdateUsersSegments, callInfo)
+	mock.lockUpdateUsersSegments.Unlock()
+	return mock.UpdateUsersSegmentsFunc(ctx, updates)
+}
+
+// UpdateUsersSegmentsCalls gets all the calls that were made to UpdateUsersSegments.
+// Check the length with:
+//
+//	len(mockedRepo.UpdateUsersSegmentsCalls())
+func (mock *RepoMock) UpdateUsersSegmentsCalls() []struct {
+	Ctx     context.Context
+	Updates []*model.UserSegmentsUpdate
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Updates []*model.UserSegmentsUpdate
+	}
+	mock.lockUpdateUsersSegments.RLock()
+	calls = mock.calls.UpdateUsersSegments
+	mock.lockUpdateUsersSegments.RUnlock()
+	return calls
+}
@@ -2,26 +2,86 @@ package repo
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	aModel "avito_2023/internal/audit/model"
 	"avito_2023/internal/database"
+	"avito_2023/internal/segment/bucket"
 	sModel "avito_2023/internal/segment/model"
 	"avito_2023/internal/user/model"
 )
 
+const (
+	// activeCondition filters memberships which are started and not removed or expired by TTL
+	activeCondition = "(active_from IS NULL OR active_from <= NOW()) AND (deleted_at IS NULL OR deleted_at > NOW())"
+
+	// currentCondition filters memberships which are active or scheduled to start
+	currentCondition = "deleted_at IS NULL OR deleted_at > NOW()"
+
+	// scheduledCondition filters memberships which are not started yet
+	scheduledCondition = "active_from > NOW()"
+)
+
+const (
+	// bulkChunkSize is the number of users processed in one transaction by bulk updates
+	bulkChunkSize = 1000
+
+	// batchUpdateSize is the number of per-user updates applied in one transaction by batch updates
+	batchUpdateSize = 100
+)
+
 //go:generate moq --out mocks/repo_mock.go --pkg=mocks . Repo
 
 type Repo interface {
-	// GetUserSegments - get user segments
+	// GetUserSegments - get user segments, unknown user is not found
 	GetUserSegments(ctx context.Context, userID uint) ([]*string, error)
 
+	// CreateUser - register user and assign percentage segments
+	CreateUser(ctx context.Context, userID uint) (*model.UserDB, error)
+
+	// GetUserProfile - get registered user with its memberships
+	GetUserProfile(ctx context.Context, userID uint) (*model.UserProfile, error)
+
+	// DeleteUser - erase user with its memberships, pseudonymize its history and remove it from audit log
+	DeleteUser(ctx context.Context, userID uint) error
+
+	// LookupUsersSegments - get active segments of many users in a single query,
+	// users without segments get empty lists
+	LookupUsersSegments(ctx context.Context, usersIDs []uint) (map[uint][]string, error)
+
 	// GetUserHistory - get user history
 	GetUserHistory(ctx context.Context, userID uint, month uint, year uint) ([]*model.UserHistory, error)
 
-	// UpdateUserSegments - update user segment
-	UpdateUserSegments(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error
+	// UpdateUserSegments - update user segments and report the outcome per slug,
+	// in strict mode any unknown slug rejects the whole update
+	UpdateUserSegments(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error)
+
+	// UpdateUsersSegments - update segments of many users in batched transactions and report the outcome per user
+	UpdateUsersSegments(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error)
+
+	// AddSegmentUsers - add users to segment in chunked transactions
+	AddSegmentUsers(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error)
+
+	// RemoveSegmentUsers - remove users from segment in chunked transactions
+	RemoveSegmentUsers(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error)
+
+	// ExpireUserSegments - finalize memberships with passed TTL
+	ExpireUserSegments(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error)
+
+	// ActivateUserSegments - record start of scheduled memberships which became active
+	ActivateUserSegments(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error)
+
+	// GetOldestDue - get the earliest start or TTL of memberships waiting to be activated or expired, nil if none
+	GetOldestDue(ctx context.Context) (*time.Time, error)
 }
 
 type repo struct {
@@ -29,26 +89,218 @@ type repo struct {
 }
 
 func NewRepo(db *gorm.DB) Repo {
-	return &repo{
-		db: db,
+	return &instrumentedRepo{
+		repo: &repo{
+			db: db,
+		},
 	}
 }
 
 func (r *repo) GetUserSegments(ctx context.Context, userID uint) ([]*string, error) {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	if err := db.Select("id").
+		Where("id = ?", userID).
+		First(&model.UserDB{}).Error; err != nil {
+		return nil, err
+	}
+
+	return activeUserSegments(db, userID)
+}
+
+func (r *repo) CreateUser(ctx context.Context, userID uint) (*model.UserDB, error) {
 	db := database.FromContext(ctx, r.db)
 
-	var segments []*string
-	if err := db.WithContext(ctx).
-		Model(&model.UserSegmentDB{}).
+	user := &model.UserDB{ID: userID}
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(user)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return database.ErrCreateUser_AlreadyExists
+		}
+
+		return assignAutoSegments(tx, []*model.UserDB{user})
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *repo) GetUserProfile(ctx context.Context, userID uint) (*model.UserProfile, error) {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	var user *model.UserDB
+	if err := db.Where("id = ?", userID).
+		First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	active, err := activeUserSegments(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &model.UserProfile{
+		UserID:            user.ID,
+		CreatedAt:         user.CreatedAt,
+		ActiveSegments:    make([]string, 0, len(active)),
+		ScheduledSegments: []string{},
+	}
+	for _, slug := range active {
+		profile.ActiveSegments = append(profile.ActiveSegments, *slug)
+	}
+
+	if err := db.Model(&model.UserSegmentDB{}).
+		Select("segments.slug").
+		Joins("JOIN segments ON users_segments.segment_id = segments.id").
+		Where("users_segments.user_id = ? AND users_segments.active_from > NOW()", userID).
+		Order("segments.slug").
+		Scan(&profile.ScheduledSegments).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&model.SegmentEventDB{}).
+		Select("MAX(created_at)").
+		Where("user_id = ?", userID).
+		Row().Scan(&profile.LastChangeAt); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (r *repo) DeleteUser(ctx context.Context, userID uint) error {
+	db := database.FromContext(ctx, r.db)
+
+	pseudonym, err := newPseudonym()
+	if err != nil {
+		return err
+	}
+
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user *model.UserDB
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userID).
+			First(&user).Error; err != nil {
+			return err
+		}
+
+		// history keeps segment changes, but can't be linked back to the user
+		if err := tx.Model(&model.SegmentEventDB{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"user_id": gorm.Expr("NULL"), "user_pseudonym": pseudonym}).Error; err != nil {
+			return err
+		}
+
+		// audit log keeps entries, but not the user among their users
+		if err := tx.Model(&aModel.EntryDB{}).
+			Where("users_ids @> ?::jsonb", fmt.Sprintf("[%d]", userID)).
+			Update("users_ids", gorm.Expr(
+				"(SELECT COALESCE(jsonb_agg(id), '[]'::jsonb) FROM jsonb_array_elements(users_ids) AS ids(id) WHERE id <> to_jsonb(?::bigint))",
+				userID,
+			)).Error; err != nil {
+			return err
+		}
+
+		// memberships are removed by cascade
+		return tx.Delete(user).Error
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// activeUserSegments returns active segments of the user, percentage segments are evaluated
+// for the user unless membership was materialized
+func activeUserSegments(db *gorm.DB, userID uint) ([]*string, error) {
+	segments := make([]*string, 0)
+	if err := db.Model(&model.UserSegmentDB{}).
 		Select("slug").
 		Where("user_id = ?", userID).
-		Where("deleted_at IS NULL OR deleted_at > NOW()").
+		Where(activeCondition).
 		Joins("LEFT JOIN segments ON users_segments.segment_id = segments.id").
+		Where("segments.archived_at IS NULL").
 		Scan(&segments).Error; err != nil {
 		return nil, err
 	}
-	if len(segments) == 0 {
-		return nil, database.ErrNotFound
+
+	var autoSegments []*sModel.SegmentDB
+	if err := db.Where("percentage > 0 AND archived_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM users_segments WHERE users_segments.segment_id = segments.id AND users_segments.user_id = ?)", userID).
+		Find(&autoSegments).Error; err != nil {
+		return nil, err
+	}
+	for _, segment := range autoSegments {
+		if bucket.Contains(userID, segment.Salt, segment.Percentage) {
+			segments = append(segments, &segment.Slug)
+		}
+	}
+
+	return segments, nil
+}
+
+// joinIDs makes comma separated ids parameter for queries splitting it with string_to_array
+func joinIDs(ids []uint) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatUint(uint64(id), 10)
+	}
+
+	return strings.Join(s, ",")
+}
+
+// newPseudonym generates a random replacement of erased user id
+func newPseudonym() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// lookupQuery selects active segments of the users, explicit memberships and percentage segments
+// evaluated for users without materialized membership, the same way as GetUserSegments does.
+// Unknown users get no segments, as GetUserSegments doesn't find them.
+// Ids are passed as a single comma separated parameter, so thousands of them don't hit bind parameters limit.
+var lookupQuery = `WITH ids AS (SELECT DISTINCT unnest(string_to_array(?, ',')::int[]) AS id)
+SELECT ids.id AS user_id, segments.slug FROM ids
+JOIN users_segments ON users_segments.user_id = ids.id
+JOIN segments ON segments.id = users_segments.segment_id
+WHERE segments.archived_at IS NULL
+AND (users_segments.active_from IS NULL OR users_segments.active_from <= NOW())
+AND (users_segments.deleted_at IS NULL OR users_segments.deleted_at > NOW())
+UNION
+SELECT ids.id AS user_id, segments.slug FROM ids
+JOIN users ON users.id = ids.id
+JOIN segments ON segments.percentage > 0 AND segments.archived_at IS NULL
+WHERE ` + bucket.Expr("ids.id", "segments.salt") + ` < segments.percentage
+AND NOT EXISTS (SELECT 1 FROM users_segments WHERE users_segments.segment_id = segments.id AND users_segments.user_id = ids.id)
+ORDER BY user_id, slug`
+
+func (r *repo) LookupUsersSegments(ctx context.Context, usersIDs []uint) (map[uint][]string, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var rows []struct {
+		UserID uint
+		Slug   string
+	}
+	if err := db.WithContext(ctx).
+		Raw(lookupQuery, joinIDs(usersIDs)).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	segments := make(map[uint][]string, len(usersIDs))
+	for _, id := range usersIDs {
+		segments[id] = []string{}
+	}
+	for _, row := range rows {
+		segments[row.UserID] = append(segments[row.UserID], row.Slug)
 	}
 
 	return segments, nil
@@ -57,12 +309,16 @@ func (r *repo) GetUserSegments(ctx context.Context, userID uint) ([]*string, err
 func (r *repo) GetUserHistory(ctx context.Context, userID uint, month uint, year uint) ([]*model.UserHistory, error) {
 	db := database.FromContext(ctx, r.db)
 
+	from := time.Date(int(year), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
 	var history []*model.UserHistory
 	if err := db.WithContext(ctx).
-		Model(&model.UserSegmentDB{}).
-		Select("slug", "created_at", "deleted_at").
-		Where("user_id = ? AND EXTRACT(MONTH FROM created_at) = ? AND EXTRACT(YEAR FROM created_at) = ?", userID, month, year).
-		Joins("LEFT JOIN segments ON users_segments.segment_id = segments.id").Scan(&history).Error; err != nil {
+		Model(&model.SegmentEventDB{}).
+		Select("segment_slug AS slug", "operation", "source", "created_at").
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, from, to).
+		Order("created_at, id").
+		Scan(&history).Error; err != nil {
 		return nil, err
 	}
 	if len(history) == 0 {
@@ -72,63 +328,688 @@ func (r *repo) GetUserHistory(ctx context.Context, userID uint, month uint, year
 	return history, nil
 }
 
-func (r *repo) UpdateUserSegments(ctx context.Context, userID uint, slugsToAdd, slugsToDel []string, deleteAt *time.Time) error {
+func (r *repo) UpdateUserSegments(ctx context.Context, update *model.UserSegmentsUpdate) ([]*model.SlugResult, error) {
 	db := database.FromContext(ctx, r.db)
 
+	var results []*model.SlugResult
 	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var user *model.UserDB
-		if err := tx.Where(model.UserDB{ID: userID}).
-			FirstOrCreate(&user).Error; err != nil {
+		if err := lockOrCreateUsers(tx, []uint{update.UserID}); err != nil {
+			return err
+		}
+
+		segments, err := segmentsBySlug(tx, updateSlugs(update))
+		if err != nil {
 			return err
 		}
 
-		if len(slugsToAdd) != 0 {
-			var idsToAdd []uint
-			if err := tx.Model(&sModel.SegmentDB{}).
-				Select("id").
-				Where("slug IN ?", slugsToAdd).
-				Find(&idsToAdd).Error; err != nil {
+		results, err = applyUserSegmentsUpdate(tx, update, segments)
+		return err
+	}); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+func (r *repo) UpdateUsersSegments(ctx context.Context, updates []*model.UserSegmentsUpdate) ([]*model.UserSegmentsResult, error) {
+	db := database.FromContext(ctx, r.db)
+
+	results := make([]*model.UserSegmentsResult, 0, len(updates))
+	for chunk := range slices.Chunk(updates, batchUpdateSize) {
+		var chunkResults []*model.UserSegmentsResult
+		if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			chunkResults = make([]*model.UserSegmentsResult, 0, len(chunk))
+
+			usersIDs := make([]uint, 0, len(chunk))
+			var slugs []string
+			for _, update := range chunk {
+				usersIDs = append(usersIDs, update.UserID)
+				slugs = append(slugs, updateSlugs(update)...)
+			}
+			slices.Sort(usersIDs)
+			if err := lockOrCreateUsers(tx, slices.Compact(usersIDs)); err != nil {
 				return err
 			}
-			if len(idsToAdd) == 0 {
-				return database.ErrUpdateUserSegments_InvalidSegments
+
+			segments, err := segmentsBySlug(tx, slugs)
+			if err != nil {
+				return err
 			}
 
-			userSegments := make([]*model.UserSegmentDB, 0, len(idsToAdd))
-			for _, id := range idsToAdd {
-				row := &model.UserSegmentDB{UserID: userID, SegmentID: id}
-				if deleteAt != nil {
-					row.DeletedAt = deleteAt
+			// strict update of a user is rejected before any change, so it doesn't affect the others
+			for _, update := range chunk {
+				result := &model.UserSegmentsResult{UserID: update.UserID}
+				result.Results, err = applyUserSegmentsUpdate(tx, update, segments)
+				if err != nil {
+					if !database.IsUpdateUserSegmentsUnknownSegmentsErr(err) {
+						return err
+					}
+					result.Error = err.Error()
 				}
+				chunkResults = append(chunkResults, result)
+			}
+
+			return nil
+		}); err != nil {
+			return results, err
+		}
+
+		results = append(results, chunkResults...)
+	}
+
+	return results, nil
+}
+
+// updateSlugs returns all slugs the update refers to
+func updateSlugs(update *model.UserSegmentsUpdate) []string {
+	slugs := slices.Clone(update.SlugsToDel)
+	for _, s := range update.SlugsToAdd {
+		slugs = append(slugs, s.Slug)
+	}
 
-				userSegments = append(userSegments, row)
+	return slugs
+}
+
+// applyUserSegmentsUpdate changes segments of the locked user and reports the outcome per slug
+func applyUserSegmentsUpdate(tx *gorm.DB, update *model.UserSegmentsUpdate, segments map[string]*sModel.SegmentDB) ([]*model.SlugResult, error) {
+	if update.Strict {
+		var unknown []*model.SlugResult
+		for _, s := range update.SlugsToAdd {
+			if _, ok := segments[slugKey(s.Slug)]; !ok {
+				unknown = append(unknown, &model.SlugResult{Slug: s.Slug, Operation: model.OperationAdd, Status: model.SlugStatusUnknownSegment})
 			}
-			if err := tx.Model(&model.UserSegmentDB{}).Create(&userSegments).Error; err != nil {
-				return err
+		}
+		for _, slug := range update.SlugsToDel {
+			if _, ok := segments[slugKey(slug)]; !ok {
+				unknown = append(unknown, &model.SlugResult{Slug: slug, Operation: model.OperationRemove, Status: model.SlugStatusUnknownSegment})
+			}
+		}
+		if len(unknown) != 0 {
+			return unknown, database.ErrUpdateUserSegments_UnknownSegments
+		}
+	}
+
+	added, err := addUserSegments(tx, update.UserID, update.SlugsToAdd, segments)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := removeUserSegments(tx, update.UserID, update.SlugsToDel, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(added, removed...), nil
+}
+
+// segmentsBySlug returns segments with the slugs including archived ones keyed by slugKey
+func segmentsBySlug(tx *gorm.DB, slugs []string) (map[string]*sModel.SegmentDB, error) {
+	segments := make(map[string]*sModel.SegmentDB, len(slugs))
+	if len(slugs) == 0 {
+		return segments, nil
+	}
+
+	keys := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		keys = append(keys, slugKey(slug))
+	}
+
+	var found []*sModel.SegmentDB
+	if err := tx.Where("LOWER(slug) IN ?", keys).
+		Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, segment := range found {
+		segments[slugKey(segment.Slug)] = segment
+	}
+
+	return segments, nil
+}
+
+// slugKey folds slug case, segments are looked up case-insensitively
+func slugKey(slug string) string {
+	return strings.ToLower(slug)
+}
+
+// addUserSegments opens memberships of the user in known segments and reports the outcome per slug
+func addUserSegments(tx *gorm.DB, userID uint, slugsToAdd []*model.SegmentToAdd, segments map[string]*sModel.SegmentDB) ([]*model.SlugResult, error) {
+	if len(slugsToAdd) == 0 {
+		return nil, nil
+	}
+
+	// adding an active or scheduled segment is a no-op, a previously left one opens a new period
+	var currentIDs []uint
+	if err := tx.Model(&model.UserSegmentDB{}).
+		Select("segment_id").
+		Where("user_id = ?", userID).
+		Where(currentCondition).
+		Find(&currentIDs).Error; err != nil {
+		return nil, err
+	}
+	current := make(map[uint]struct{}, len(currentIDs))
+	for _, id := range currentIDs {
+		current[id] = struct{}{}
+	}
+
+	results := make([]*model.SlugResult, 0, len(slugsToAdd))
+	userSegments := make([]*model.UserSegmentDB, 0, len(slugsToAdd))
+	events := make([]*model.SegmentEventDB, 0, len(slugsToAdd))
+	seen := make(map[string]struct{}, len(slugsToAdd))
+	for _, s := range slugsToAdd {
+		if _, ok := seen[slugKey(s.Slug)]; ok {
+			continue
+		}
+		seen[slugKey(s.Slug)] = struct{}{}
+
+		result := &model.SlugResult{Slug: s.Slug, Operation: model.OperationAdd}
+		results = append(results, result)
+
+		segment, ok := segments[slugKey(s.Slug)]
+		switch {
+		case !ok:
+			result.Status = model.SlugStatusUnknownSegment
+			continue
+		case segment.ArchivedAt != nil:
+			result.Status = model.SlugStatusArchived
+			continue
+		}
+		if _, ok := current[segment.ID]; ok {
+			result.Status = model.SlugStatusAlreadyMember
+			continue
+		}
+		result.Status = model.SlugStatusAdded
+		current[segment.ID] = struct{}{}
+
+		row := &model.UserSegmentDB{
+			UserID:     userID,
+			SegmentID:  segment.ID,
+			ActiveFrom: s.ActiveFrom,
+			DeletedAt:  s.DeleteAt,
+			Source:     model.SourceManual,
+		}
+		if row.DeletedAt == nil {
+			from := time.Now()
+			if s.ActiveFrom != nil {
+				from = *s.ActiveFrom
 			}
+			row.DeletedAt = segment.DefaultDeleteAt(from)
+		}
+		// start of scheduled membership is recorded by the worker at activation time
+		if s.ActiveFrom != nil {
+			row.Pending = true
+		} else {
+			events = append(events, model.NewSegmentEvent(userID, segment, model.OperationAdd, model.SourceManual))
+		}
+
+		userSegments = append(userSegments, row)
+	}
+	if len(userSegments) != 0 {
+		if err := tx.Model(&model.UserSegmentDB{}).Create(&userSegments).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(events) != 0 {
+		if err := tx.Create(&events).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// removeUserSegments closes memberships of the user in known segments and reports the outcome per slug
+func removeUserSegments(tx *gorm.DB, userID uint, slugsToDel []string, segments map[string]*sModel.SegmentDB) ([]*model.SlugResult, error) {
+	if len(slugsToDel) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(slugsToDel))
+	for _, slug := range slugsToDel {
+		if segment, ok := segments[slugKey(slug)]; ok && segment.ArchivedAt == nil {
+			ids = append(ids, segment.ID)
+		}
+	}
+
+	var current []struct {
+		SegmentID uint
+		Scheduled bool
+	}
+	if len(ids) != 0 {
+		if err := tx.Model(&model.UserSegmentDB{}).
+			Select("segment_id", "COALESCE(active_from > NOW(), FALSE) AS scheduled").
+			Where("user_id = ? AND segment_id IN ?", userID, ids).
+			Where(currentCondition).
+			Scan(&current).Error; err != nil {
+			return nil, err
+		}
+	}
+	scheduled := make(map[uint]bool, len(current))
+	for _, row := range current {
+		scheduled[row.SegmentID] = row.Scheduled
+	}
+
+	results := make([]*model.SlugResult, 0, len(slugsToDel))
+	var scheduledIDs, activeIDs []uint
+	var events []*model.SegmentEventDB
+	seen := make(map[string]struct{}, len(slugsToDel))
+	for _, slug := range slugsToDel {
+		if _, ok := seen[slugKey(slug)]; ok {
+			continue
 		}
+		seen[slugKey(slug)] = struct{}{}
+
+		result := &model.SlugResult{Slug: slug, Operation: model.OperationRemove}
+		results = append(results, result)
+
+		segment, ok := segments[slugKey(slug)]
+		switch {
+		case !ok:
+			result.Status = model.SlugStatusUnknownSegment
+			continue
+		case segment.ArchivedAt != nil:
+			result.Status = model.SlugStatusArchived
+			continue
+		}
+		isScheduled, ok := scheduled[segment.ID]
+		if !ok {
+			result.Status = model.SlugStatusNotMember
+			continue
+		}
+		result.Status = model.SlugStatusRemoved
+
+		if isScheduled {
+			scheduledIDs = append(scheduledIDs, segment.ID)
+		} else {
+			activeIDs = append(activeIDs, segment.ID)
+			events = append(events, model.NewSegmentEvent(userID, segment, model.OperationRemove, model.SourceManual))
+		}
+	}
+
+	// scheduled memberships never started, so they are dropped without history
+	if len(scheduledIDs) != 0 {
+		if err := tx.Where("user_id = ? AND segment_id IN ?", userID, scheduledIDs).
+			Where(scheduledCondition).
+			Delete(&model.UserSegmentDB{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	// only active memberships are closed, so expired periods keep their deleted_at
+	if len(activeIDs) != 0 {
+		if err := tx.Model(&model.UserSegmentDB{}).
+			Where("user_id = ? AND segment_id IN ?", userID, activeIDs).
+			Where(activeCondition).
+			Updates(map[string]interface{}{"deleted_at": gorm.Expr("NOW()"), "finalized": true}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&events).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (r *repo) AddSegmentUsers(ctx context.Context, slug string, usersIDs []uint, deleteAt *time.Time) (*model.BulkResult, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var segment *sModel.SegmentDB
+	if err := db.WithContext(ctx).
+		Where("LOWER(slug) = LOWER(?) AND archived_at IS NULL", slug).
+		First(&segment).Error; err != nil {
+		return nil, err
+	}
+	if deleteAt == nil {
+		deleteAt = segment.DefaultDeleteAt(time.Now())
+	}
 
-		if len(slugsToDel) != 0 {
-			var idsToDel []uint
-			if err := tx.Model(&sModel.SegmentDB{}).
-				Select("id").
-				Where("slug IN ?", slugsToDel).
-				Find(&idsToDel).Error; err != nil {
+	result := &model.BulkResult{}
+	for _, chunk := range chunkUsers(usersIDs) {
+		if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lockOrCreateUsers(tx, chunk); err != nil {
 				return err
 			}
-			if len(idsToDel) == 0 {
-				return nil
+
+			active, err := segmentUsers(tx, segment.ID, chunk, currentCondition)
+			if err != nil {
+				return err
 			}
-			if err := tx.Model(&model.UserSegmentDB{}).Where("user_id = ? AND segment_id IN ?", userID, idsToDel).
-				Update("deleted_at", "NOW()").Error; err != nil {
+
+			userSegments := make([]*model.UserSegmentDB, 0, len(chunk))
+			events := make([]*model.SegmentEventDB, 0, len(chunk))
+			for _, userID := range chunk {
+				if _, ok := active[userID]; ok {
+					continue
+				}
+
+				userSegments = append(userSegments, &model.UserSegmentDB{UserID: userID, SegmentID: segment.ID, DeletedAt: deleteAt, Source: model.SourceBulk})
+				events = append(events, model.NewSegmentEvent(userID, segment, model.OperationAdd, model.SourceBulk))
+			}
+			if len(userSegments) != 0 {
+				if err := tx.CreateInBatches(&userSegments, database.InsertBatchSize).Error; err != nil {
+					return err
+				}
+				if err := tx.CreateInBatches(&events, database.InsertBatchSize).Error; err != nil {
+					return err
+				}
+			}
+
+			result.Added += uint(len(userSegments))
+			result.Skipped += uint(len(active))
+			return nil
+		}); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repo) RemoveSegmentUsers(ctx context.Context, slug string, usersIDs []uint) (*model.BulkResult, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var segment *sModel.SegmentDB
+	if err := db.WithContext(ctx).
+		Where("LOWER(slug) = LOWER(?)", slug).
+		First(&segment).Error; err != nil {
+		return nil, err
+	}
+
+	result := &model.BulkResult{}
+	for _, chunk := range chunkUsers(usersIDs) {
+		if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// scheduled memberships never started, so they are dropped without history
+			if err := tx.Where("segment_id = ? AND user_id IN ?", segment.ID, chunk).
+				Where(scheduledCondition).
+				Delete(&model.UserSegmentDB{}).Error; err != nil {
 				return err
 			}
+
+			active, err := segmentUsers(tx, segment.ID, chunk, activeCondition)
+			if err != nil {
+				return err
+			}
+
+			idsToDel := make([]uint, 0, len(active))
+			events := make([]*model.SegmentEventDB, 0, len(active))
+			for _, userID := range chunk {
+				if _, ok := active[userID]; !ok {
+					continue
+				}
+
+				idsToDel = append(idsToDel, userID)
+				events = append(events, model.NewSegmentEvent(userID, segment, model.OperationRemove, model.SourceBulk))
+			}
+			if len(idsToDel) != 0 {
+				if err := tx.Model(&model.UserSegmentDB{}).
+					Where("segment_id = ? AND user_id IN ?", segment.ID, idsToDel).
+					Where(activeCondition).
+					Updates(map[string]interface{}{"deleted_at": gorm.Expr("NOW()"), "finalized": true}).Error; err != nil {
+					return err
+				}
+				if err := tx.CreateInBatches(&events, database.InsertBatchSize).Error; err != nil {
+					return err
+				}
+			}
+
+			result.Removed += uint(len(idsToDel))
+			result.Skipped += uint(len(chunk) - len(idsToDel))
+			return nil
+		}); err != nil {
+			return result, err
 		}
+	}
 
-		return nil
+	return result, nil
+}
+
+func (r *repo) ExpireUserSegments(ctx context.Context, limit int) ([]*model.ExpiredUserSegment, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var expired []*model.ExpiredUserSegment
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// skip rows locked by concurrent workers or user updates
+		if err := tx.Model(&model.UserSegmentDB{}).
+			Select("users_segments.id", "users_segments.user_id", "users_segments.segment_id", "segments.slug", "users_segments.deleted_at").
+			Joins("JOIN segments ON users_segments.segment_id = segments.id").
+			Where("NOT users_segments.finalized AND users_segments.deleted_at <= NOW()").
+			Order("users_segments.deleted_at").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "users_segments"}, Options: "SKIP LOCKED"}).
+			Scan(&expired).Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(expired))
+		events := make([]*model.SegmentEventDB, len(expired))
+		for i, row := range expired {
+			ids[i] = row.ID
+			events[i] = &model.SegmentEventDB{
+				UserID:      row.UserID,
+				SegmentID:   &row.SegmentID,
+				SegmentSlug: row.Slug,
+				Operation:   model.OperationRemove,
+				Source:      model.SourceTTL,
+				CreatedAt:   row.DeletedAt,
+			}
+		}
+		if err := tx.Model(&model.UserSegmentDB{}).
+			Where("id IN ?", ids).
+			Update("finalized", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&events).Error
 	}); err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+func (r *repo) ActivateUserSegments(ctx context.Context, limit int) ([]*model.ActivatedUserSegment, error) {
+	db := database.FromContext(ctx, r.db)
+
+	var activated []*model.ActivatedUserSegment
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// skip rows locked by concurrent workers or user updates
+		if err := tx.Model(&model.UserSegmentDB{}).
+			Select("users_segments.id", "users_segments.user_id", "users_segments.segment_id", "segments.slug",
+				"users_segments.source", "users_segments.active_from").
+			Joins("JOIN segments ON users_segments.segment_id = segments.id").
+			Where("users_segments.pending AND users_segments.active_from <= NOW()").
+			Order("users_segments.active_from").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "users_segments"}, Options: "SKIP LOCKED"}).
+			Scan(&activated).Error; err != nil {
+			return err
+		}
+		if len(activated) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(activated))
+		events := make([]*model.SegmentEventDB, len(activated))
+		for i, row := range activated {
+			ids[i] = row.ID
+			events[i] = &model.SegmentEventDB{
+				UserID:      row.UserID,
+				SegmentID:   &row.SegmentID,
+				SegmentSlug: row.Slug,
+				Operation:   model.OperationAdd,
+				Source:      row.Source,
+				CreatedAt:   row.ActiveFrom,
+			}
+		}
+		if err := tx.Model(&model.UserSegmentDB{}).
+			Where("id IN ?", ids).
+			Update("pending", false).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&events).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return activated, nil
+}
+
+func (r *repo) GetOldestDue(ctx context.Context) (*time.Time, error) {
+	db := database.FromContext(ctx, r.db).WithContext(ctx)
+
+	// LEAST ignores NULL, so the result is NULL only if nothing is due
+	var row struct {
+		OldestDueAt *time.Time
+	}
+	if err := db.Raw(`SELECT LEAST(
+		(SELECT MIN(deleted_at) FROM users_segments WHERE NOT finalized AND deleted_at <= NOW()),
+		(SELECT MIN(active_from) FROM users_segments WHERE pending AND active_from <= NOW())
+	) AS oldest_due_at`).
+		Scan(&row).Error; err != nil {
+		return nil, err
+	}
+
+	return row.OldestDueAt, nil
+}
+
+// createUsersQuery creates missing users in id order and returns ids of created ones,
+// users created by a concurrent transaction are skipped once it commits
+var createUsersQuery = `INSERT INTO users (id)
+SELECT id FROM unnest(string_to_array(?, ',')::int[]) AS ids(id) ORDER BY id
+ON CONFLICT DO NOTHING
+RETURNING id`
+
+// lockOrCreateUsers locks the users, so concurrent updates can't open the same membership twice.
+// Users created by this transaction are assigned to percentage segments they fall into.
+func lockOrCreateUsers(tx *gorm.DB, usersIDs []uint) error {
+	existing, err := lockUsers(tx, usersIDs)
+	if err != nil {
 		return err
 	}
+	missingIDs := make([]uint, 0, len(usersIDs)-len(existing))
+	for _, id := range usersIDs {
+		if _, ok := existing[id]; !ok {
+			missingIDs = append(missingIDs, id)
+		}
+	}
+	if len(missingIDs) == 0 {
+		return nil
+	}
 
-	return nil
+	var createdIDs []uint
+	if err := tx.Raw(createUsersQuery, joinIDs(missingIDs)).
+		Scan(&createdIDs).Error; err != nil {
+		return err
+	}
+	created := make(map[uint]struct{}, len(createdIDs))
+	newUsers := make([]*model.UserDB, 0, len(createdIDs))
+	for _, id := range createdIDs {
+		created[id] = struct{}{}
+		newUsers = append(newUsers, &model.UserDB{ID: id})
+	}
+
+	// users created concurrently are locked now, their percentage segments are assigned by their creator
+	var concurrentIDs []uint
+	for _, id := range missingIDs {
+		if _, ok := created[id]; !ok {
+			concurrentIDs = append(concurrentIDs, id)
+		}
+	}
+	if len(concurrentIDs) > 0 {
+		if _, err := lockUsers(tx, concurrentIDs); err != nil {
+			return err
+		}
+	}
+	if len(newUsers) == 0 {
+		return nil
+	}
+
+	return assignAutoSegments(tx, newUsers)
+}
+
+// lockUsers locks existing users of the list in id order and returns their ids
+func lockUsers(tx *gorm.DB, usersIDs []uint) (map[uint]struct{}, error) {
+	var ids []uint
+	if err := tx.Model(&model.UserDB{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id IN ?", usersIDs).
+		Order("id").
+		Find(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	existing := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		existing[id] = struct{}{}
+	}
+
+	return existing, nil
+}
+
+// assignAutoSegments opens memberships of new users in percentage segments they fall into
+func assignAutoSegments(tx *gorm.DB, newUsers []*model.UserDB) error {
+	var autoSegments []*sModel.SegmentDB
+	if err := tx.Where("percentage > 0 AND archived_at IS NULL").
+		Find(&autoSegments).Error; err != nil {
+		return err
+	}
+
+	var (
+		userSegments []*model.UserSegmentDB
+		events       []*model.SegmentEventDB
+	)
+	for _, user := range newUsers {
+		for _, segment := range autoSegments {
+			if !bucket.Contains(user.ID, segment.Salt, segment.Percentage) {
+				continue
+			}
+
+			userSegments = append(userSegments, &model.UserSegmentDB{
+				UserID:    user.ID,
+				SegmentID: segment.ID,
+				DeletedAt: segment.DefaultDeleteAt(time.Now()),
+				Source:    model.SourcePercentage,
+			})
+			events = append(events, model.NewSegmentEvent(user.ID, segment, model.OperationAdd, model.SourcePercentage))
+		}
+	}
+	if len(userSegments) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(&userSegments, database.InsertBatchSize).Error; err != nil {
+		return err
+	}
+
+	return tx.CreateInBatches(&events, database.InsertBatchSize).Error
+}
+
+// segmentUsers returns users of the list having membership in the segment matching condition
+func segmentUsers(tx *gorm.DB, segmentID uint, usersIDs []uint, condition string) (map[uint]struct{}, error) {
+	var activeIDs []uint
+	if err := tx.Model(&model.UserSegmentDB{}).
+		Select("user_id").
+		Where("segment_id = ? AND user_id IN ?", segmentID, usersIDs).
+		Where(condition).
+		Find(&activeIDs).Error; err != nil {
+		return nil, err
+	}
+
+	active := make(map[uint]struct{}, len(activeIDs))
+	for _, id := range activeIDs {
+		active[id] = struct{}{}
+	}
+
+	return active, nil
+}
+
+// chunkUsers splits sorted users ids into chunks processed in separate transactions
+func chunkUsers(usersIDs []uint) [][]uint {
+	sorted := slices.Clone(usersIDs)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	return slices.Collect(slices.Chunk(sorted, bulkChunkSize))
 }
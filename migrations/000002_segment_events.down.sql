@@ -0,0 +1 @@
+DROP TABLE IF EXISTS segment_events;
\ No newline at end of file
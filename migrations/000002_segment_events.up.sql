@@ -0,0 +1,26 @@
+-- segment_events
+CREATE TABLE segment_events (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    segment_id INT,
+    segment_slug VARCHAR(50) NOT NULL,
+    operation VARCHAR(10) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT check_segment_events_operation CHECK (operation IN ('add', 'remove')),
+    CONSTRAINT fk_segment_events_segment_id FOREIGN KEY (segment_id) REFERENCES segments (id) ON DELETE SET NULL
+);
+CREATE INDEX idx_segment_events_user_id ON segment_events(user_id);
+CREATE INDEX idx_segment_events_created_at ON segment_events(created_at);
+
+-- history of existing memberships
+INSERT INTO segment_events (user_id, segment_id, segment_slug, operation, source, created_at)
+SELECT users_segments.user_id, segments.id, segments.slug, 'add', 'backfill', users_segments.created_at
+FROM users_segments
+JOIN segments ON users_segments.segment_id = segments.id;
+
+INSERT INTO segment_events (user_id, segment_id, segment_slug, operation, source, created_at)
+SELECT users_segments.user_id, segments.id, segments.slug, 'remove', 'backfill', users_segments.deleted_at
+FROM users_segments
+JOIN segments ON users_segments.segment_id = segments.id
+WHERE users_segments.deleted_at <= NOW();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_segments_user_id_segment_id;
+DELETE FROM users_segments old
+USING users_segments latest
+WHERE old.user_id = latest.user_id AND old.segment_id = latest.segment_id AND old.id < latest.id;
+ALTER TABLE users_segments ADD CONSTRAINT unique_users_segments_user_id_segment_id UNIQUE (user_id, segment_id);
@@ -0,0 +1,3 @@
+-- users_segments keeps a row per membership period, so a user can rejoin a segment
+ALTER TABLE users_segments DROP CONSTRAINT unique_users_segments_user_id_segment_id;
+CREATE INDEX idx_users_segments_user_id_segment_id ON users_segments(user_id, segment_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_segments_due;
+ALTER TABLE users_segments DROP COLUMN IF EXISTS finalized;
@@ -0,0 +1,4 @@
+-- finalized is set once the end of the membership is recorded in segment_events
+ALTER TABLE users_segments ADD COLUMN finalized BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users_segments SET finalized = TRUE WHERE deleted_at <= NOW();
+CREATE INDEX idx_users_segments_due ON users_segments(deleted_at) WHERE NOT finalized AND deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE users_segments DROP COLUMN IF EXISTS source;
+ALTER TABLE segments DROP CONSTRAINT IF EXISTS check_segments_percentage;
+ALTER TABLE segments DROP COLUMN IF EXISTS salt;
+ALTER TABLE segments DROP COLUMN IF EXISTS percentage;
@@ -0,0 +1,7 @@
+-- percentage of users automatically assigned to the segment by a stable hash of user id and salt
+ALTER TABLE segments ADD COLUMN percentage SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE segments ADD COLUMN salt VARCHAR(32) NOT NULL DEFAULT md5(random()::text);
+ALTER TABLE segments ADD CONSTRAINT check_segments_percentage CHECK (percentage BETWEEN 0 AND 100);
+
+-- source of the membership: manual or percentage
+ALTER TABLE users_segments ADD COLUMN source VARCHAR(20) NOT NULL DEFAULT 'manual';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_segments_slug_pattern;
+ALTER TABLE segments DROP COLUMN IF EXISTS created_at;
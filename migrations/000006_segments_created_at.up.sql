@@ -0,0 +1,2 @@
+ALTER TABLE segments ADD COLUMN created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+CREATE INDEX idx_segments_slug_pattern ON segments(slug varchar_pattern_ops);
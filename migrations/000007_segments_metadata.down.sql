@@ -0,0 +1,3 @@
+ALTER TABLE segments DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE segments DROP COLUMN IF EXISTS owner_team;
+ALTER TABLE segments DROP COLUMN IF EXISTS description;
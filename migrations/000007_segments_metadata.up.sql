@@ -0,0 +1,4 @@
+ALTER TABLE segments ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE segments ADD COLUMN owner_team VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE segments ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+UPDATE segments SET updated_at = created_at;
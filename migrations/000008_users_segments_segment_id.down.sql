@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_users_segments_segment_id_user_id;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX idx_users_segments_segment_id_user_id ON users_segments(segment_id, user_id);
@@ -0,0 +1 @@
+ALTER TABLE segments DROP COLUMN IF EXISTS archived_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- archived segments are kept with their memberships and history, but can't be assigned
+ALTER TABLE segments ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_segments_pending;
+DELETE FROM users_segments WHERE pending;
+ALTER TABLE users_segments DROP COLUMN IF EXISTS pending;
+ALTER TABLE users_segments DROP COLUMN IF EXISTS active_from;
@@ -0,0 +1,4 @@
+-- active_from schedules the start of the membership, pending is set until the start is recorded in segment_events
+ALTER TABLE users_segments ADD COLUMN active_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users_segments ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX idx_users_segments_pending ON users_segments(active_from) WHERE pending;
@@ -0,0 +1,2 @@
+-- default_ttl_seconds limits memberships added without explicit TTL, 0 means unlimited
+ALTER TABLE segments ADD COLUMN default_ttl_seconds BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DELETE FROM segment_events WHERE user_id IS NULL;
+ALTER TABLE segment_events DROP COLUMN IF EXISTS user_pseudonym;
+ALTER TABLE segment_events ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,3 @@
+-- history of erased users keeps a random pseudonym instead of user id
+ALTER TABLE segment_events ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE segment_events ADD COLUMN user_pseudonym VARCHAR(32);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_segments_slug_lower_pattern;
+CREATE INDEX idx_segments_slug_pattern ON segments(slug varchar_pattern_ops);
+DROP INDEX IF EXISTS idx_segments_slug_lower;
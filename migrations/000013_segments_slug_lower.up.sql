@@ -0,0 +1,24 @@
+-- slugs differing only in case can't be merged automatically as both may have members,
+-- so the migration stops listing them to be renamed or purged by hand
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(slugs, '; ')
+    INTO duplicates
+    FROM (
+        SELECT string_agg(slug, ', ' ORDER BY id) AS slugs
+        FROM segments
+        GROUP BY LOWER(slug)
+        HAVING COUNT(*) > 1
+    ) AS groups;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'segments slugs differ only in case, rename or purge them before migrating: %', duplicates;
+    END IF;
+END $$;
+
+-- slugs are looked up case-insensitively, so slugs differing only in case are not allowed
+CREATE UNIQUE INDEX idx_segments_slug_lower ON segments(LOWER(slug));
+DROP INDEX IF EXISTS idx_segments_slug_pattern;
+CREATE INDEX idx_segments_slug_lower_pattern ON segments(LOWER(slug) varchar_pattern_ops);
@@ -0,0 +1,22 @@
+-- audit_log records every mutating request with its caller and outcome
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(10) NOT NULL DEFAULT '',
+    client_ip VARCHAR(45) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    segments JSONB NOT NULL DEFAULT '[]',
+    users_ids JSONB NOT NULL DEFAULT '[]',
+    users_count INT NOT NULL DEFAULT 0,
+    body_sha256 VARCHAR(64) NOT NULL DEFAULT '',
+    status INT NOT NULL,
+    error_code VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX idx_audit_log_segments ON audit_log USING GIN (segments jsonb_path_ops);
+CREATE INDEX idx_audit_log_users_ids ON audit_log USING GIN (users_ids jsonb_path_ops);